@@ -2,13 +2,12 @@ package apiv1
 
 import (
 	"errors"
-	"io/ioutil"
-	"os"
+	"fmt"
 	"strconv"
-	"strings"
-	"time"
 
+	hostv1 "github.com/emccode/govmax/host/v1"
 	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
 )
 
 ///////////////////////////////////////////////////////////////
@@ -16,7 +15,11 @@ import (
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStorageArrays() ([]string, error) {
-	arrays, err := smis.EnumerateInstances("Symm_StorageSystem", true, true, nil)
+	return smis.GetStorageArraysCtx(context.Background())
+}
+
+func (smis *SMIS) GetStorageArraysCtx(ctx context.Context) ([]string, error) {
+	arrays, err := smis.EnumerateInstancesCtx(ctx, "Symm_StorageSystem", true, true, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -37,7 +40,11 @@ func (smis *SMIS) GetStorageArrays() ([]string, error) {
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStorageInstanceName(sid string) (*gowbem.InstanceName, error) {
-	arrays, err := smis.EnumerateInstances("Symm_StorageSystem", true, true, nil)
+	return smis.GetStorageInstanceNameCtx(context.Background(), sid)
+}
+
+func (smis *SMIS) GetStorageInstanceNameCtx(ctx context.Context, sid string) (*gowbem.InstanceName, error) {
+	arrays, err := smis.EnumerateInstancesCtx(ctx, "Symm_StorageSystem", true, true, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -50,7 +57,7 @@ func (smis *SMIS) GetStorageInstanceName(sid string) (*gowbem.InstanceName, erro
 			return array.InstanceName, nil
 		}
 	}
-	return nil, errors.New("Array not found")
+	return nil, fmt.Errorf("Array %w", ErrNotFound)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -58,12 +65,16 @@ func (smis *SMIS) GetStorageInstanceName(sid string) (*gowbem.InstanceName, erro
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStorageConfigurationService(systemInstanceName *gowbem.InstanceName) (*gowbem.InstanceName, error) {
-	configServices, err := smis.AssociatorNames(systemInstanceName, "", "EMC_StorageConfigurationService", nil, nil)
+	return smis.GetStorageConfigurationServiceCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetStorageConfigurationServiceCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) (*gowbem.InstanceName, error) {
+	configServices, err := smis.AssociatorNamesCtx(ctx, systemInstanceName, "", "EMC_StorageConfigurationService", nil, nil)
 	if err != nil {
 		return nil, err
 	}
 	if len(configServices) < 1 {
-		return nil, errors.New("EMC_StorageConfigurationService: not found")
+		return nil, fmt.Errorf("EMC_StorageConfigurationService: %w", ErrNotFound)
 	}
 	return configServices[0].InstancePath.InstanceName, nil
 }
@@ -73,12 +84,16 @@ func (smis *SMIS) GetStorageConfigurationService(systemInstanceName *gowbem.Inst
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetControllerConfigurationService(systemInstanceName *gowbem.InstanceName) (*gowbem.InstanceName, error) {
-	controllerServices, err := smis.AssociatorNames(systemInstanceName, "", "EMC_ControllerConfigurationService", nil, nil)
+	return smis.GetControllerConfigurationServiceCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetControllerConfigurationServiceCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) (*gowbem.InstanceName, error) {
+	controllerServices, err := smis.AssociatorNamesCtx(ctx, systemInstanceName, "", "EMC_ControllerConfigurationService", nil, nil)
 	if err != nil {
 		return nil, err
 	}
 	if len(controllerServices) < 1 {
-		return nil, errors.New("EMC_ControllerConfigurationService: not found")
+		return nil, fmt.Errorf("EMC_ControllerConfigurationService: %w", ErrNotFound)
 	}
 	return controllerServices[0].InstancePath.InstanceName, nil
 }
@@ -88,12 +103,16 @@ func (smis *SMIS) GetControllerConfigurationService(systemInstanceName *gowbem.I
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStorageHardwareIDManagementService(systemInstanceName *gowbem.InstanceName) (*gowbem.InstanceName, error) {
-	managementServices, err := smis.AssociatorNames(systemInstanceName, "", "Symm_StorageHardwareIDManagementService", nil, nil)
+	return smis.GetStorageHardwareIDManagementServiceCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetStorageHardwareIDManagementServiceCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) (*gowbem.InstanceName, error) {
+	managementServices, err := smis.AssociatorNamesCtx(ctx, systemInstanceName, "", "Symm_StorageHardwareIDManagementService", nil, nil)
 	if err != nil {
 		return nil, err
 	}
 	if len(managementServices) < 1 {
-		return nil, errors.New("Symm_StorageHardwareIDManagementService: not found")
+		return nil, fmt.Errorf("Symm_StorageHardwareIDManagementService: %w", ErrNotFound)
 	}
 	return managementServices[0].InstancePath.InstanceName, nil
 }
@@ -103,12 +122,16 @@ func (smis *SMIS) GetStorageHardwareIDManagementService(systemInstanceName *gowb
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetSoftwareIdentity(systemInstanceName *gowbem.InstanceName) (*gowbem.Instance, error) {
-	softwareIdents, err := smis.AssociatorInstances(systemInstanceName, "", "Symm_StorageSystemSoftwareIdentity", nil, nil, true, nil)
+	return smis.GetSoftwareIdentityCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetSoftwareIdentityCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) (*gowbem.Instance, error) {
+	softwareIdents, err := smis.AssociatorInstancesCtx(ctx, systemInstanceName, "", "Symm_StorageSystemSoftwareIdentity", nil, nil, true, nil)
 	if err != nil {
 		return nil, err
 	}
 	if len(softwareIdents) < 1 {
-		return nil, errors.New("Symm_StorageSystemSoftwareIdentity: not found")
+		return nil, fmt.Errorf("Symm_StorageSystemSoftwareIdentity: %w", ErrNotFound)
 	}
 	return softwareIdents[0].Instance, nil
 }
@@ -118,7 +141,11 @@ func (smis *SMIS) GetSoftwareIdentity(systemInstanceName *gowbem.InstanceName) (
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) IsArrayV3(systemInstanceName *gowbem.InstanceName) bool {
-	swIdent, err := smis.GetSoftwareIdentity(systemInstanceName)
+	return smis.IsArrayV3Ctx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) IsArrayV3Ctx(ctx context.Context, systemInstanceName *gowbem.InstanceName) bool {
+	swIdent, err := smis.GetSoftwareIdentityCtx(ctx, systemInstanceName)
 	if err != nil {
 		return false
 	}
@@ -137,10 +164,14 @@ func (smis *SMIS) IsArrayV3(systemInstanceName *gowbem.InstanceName) bool {
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStoragePools(systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	if smis.IsArrayV3(systemInstanceName) {
-		return smis.AssociatorNames(systemInstanceName, "", "Symm_SRPStoragePool", nil, nil)
+	return smis.GetStoragePoolsCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetStoragePoolsCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	if smis.IsArrayV3Ctx(ctx, systemInstanceName) {
+		return smis.AssociatorNamesCtx(ctx, systemInstanceName, "", "Symm_SRPStoragePool", nil, nil)
 	} else {
-		return smis.AssociatorNames(systemInstanceName, "", "Symm_VirtualProvisioningPool", nil, nil)
+		return smis.AssociatorNamesCtx(ctx, systemInstanceName, "", "Symm_VirtualProvisioningPool", nil, nil)
 	}
 }
 
@@ -149,7 +180,11 @@ func (smis *SMIS) GetStoragePools(systemInstanceName *gowbem.InstanceName) ([]go
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetMaskingViews(systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	return smis.AssociatorNames(systemInstanceName, "", "Symm_LunMaskingView", nil, nil)
+	return smis.GetMaskingViewsCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetMaskingViewsCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	return smis.associatorPathsCtx(ctx, systemInstanceName, MaskingViewClass)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -157,11 +192,15 @@ func (smis *SMIS) GetMaskingViews(systemInstanceName *gowbem.InstanceName) ([]go
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStorageGroups(systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	controllerService, err := smis.GetControllerConfigurationService(systemInstanceName)
+	return smis.GetStorageGroupsCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetStorageGroupsCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	controllerService, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstanceName)
 	if err != nil {
 		return nil, err
 	}
-	return smis.AssociatorNames(controllerService, "", "SE_DeviceMaskingGroup", nil, nil)
+	return smis.AssociatorNamesCtx(ctx, controllerService, "", StorageGroupClass, nil, nil)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -169,11 +208,15 @@ func (smis *SMIS) GetStorageGroups(systemInstanceName *gowbem.InstanceName) ([]g
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetPortGroups(systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	controllerService, err := smis.GetControllerConfigurationService(systemInstanceName)
+	return smis.GetPortGroupsCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetPortGroupsCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	controllerService, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstanceName)
 	if err != nil {
 		return nil, err
 	}
-	return smis.AssociatorNames(controllerService, "", "SE_TargetMaskingGroup", nil, nil)
+	return smis.AssociatorNamesCtx(ctx, controllerService, "", PortGroupClass, nil, nil)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -181,11 +224,15 @@ func (smis *SMIS) GetPortGroups(systemInstanceName *gowbem.InstanceName) ([]gowb
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetHostGroups(systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	controllerService, err := smis.GetControllerConfigurationService(systemInstanceName)
+	return smis.GetHostGroupsCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetHostGroupsCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	controllerService, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstanceName)
 	if err != nil {
 		return nil, err
 	}
-	return smis.AssociatorNames(controllerService, "", "SE_InitiatorMaskingGroup", nil, nil)
+	return smis.AssociatorNamesCtx(ctx, controllerService, "", InitiatorGroupClass, nil, nil)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -193,7 +240,11 @@ func (smis *SMIS) GetHostGroups(systemInstanceName *gowbem.InstanceName) ([]gowb
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetVolumes(systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	return smis.AssociatorNames(systemInstance, "", "CIM_StorageVolume", nil, nil)
+	return smis.GetVolumesCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) GetVolumesCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	return smis.associatorPathsCtx(ctx, systemInstance, "CIM_StorageVolume")
 }
 
 ///////////////////////////////////////////////////////////
@@ -201,7 +252,11 @@ func (smis *SMIS) GetVolumes(systemInstance *gowbem.InstanceName) ([]gowbem.Obje
 ///////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetVolumeByID(systemInstance *gowbem.InstanceName, volumeID string) (*gowbem.InstanceName, error) {
-	volumes, err := smis.GetVolumes(systemInstance)
+	return smis.GetVolumeByIDCtx(context.Background(), systemInstance, volumeID)
+}
+
+func (smis *SMIS) GetVolumeByIDCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volumeID string) (*gowbem.InstanceName, error) {
+	volumes, err := smis.GetVolumesCtx(ctx, systemInstance)
 	if err != nil {
 		return nil, err
 	}
@@ -213,7 +268,7 @@ func (smis *SMIS) GetVolumeByID(systemInstance *gowbem.InstanceName, volumeID st
 			}
 		}
 	}
-	return nil, errors.New("Volume not found")
+	return nil, fmt.Errorf("Volume %w", ErrNotFound)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -221,7 +276,11 @@ func (smis *SMIS) GetVolumeByID(systemInstance *gowbem.InstanceName, volumeID st
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStorageProcessorSystem(systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	return smis.AssociatorNames(systemInstance, "", "Symm_StorageProcessorSystem", nil, nil)
+	return smis.GetStorageProcessorSystemCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) GetStorageProcessorSystemCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	return smis.AssociatorNamesCtx(ctx, systemInstance, "", "Symm_StorageProcessorSystem", nil, nil)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -229,11 +288,15 @@ func (smis *SMIS) GetStorageProcessorSystem(systemInstance *gowbem.InstanceName)
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetScsiInitiators(systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	service, err := smis.GetStorageHardwareIDManagementService(systemInstance)
+	return smis.GetScsiInitiatorsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) GetScsiInitiatorsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	service, err := smis.GetStorageHardwareIDManagementServiceCtx(ctx, systemInstance)
 	if err != nil {
 		return nil, err
 	}
-	return smis.AssociatorNames(service, "", "SE_StorageHardwareID", nil, nil)
+	return smis.associatorPathsCtx(ctx, service, "SE_StorageHardwareID")
 }
 
 ///////////////////////////////////////////////////////////////
@@ -241,7 +304,11 @@ func (smis *SMIS) GetScsiInitiators(systemInstance *gowbem.InstanceName) ([]gowb
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetScsiEndpoints(storageProcessor *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	return smis.AssociatorNames(storageProcessor, "", "CIM_SCSIProtocolEndpoint", nil, nil)
+	return smis.GetScsiEndpointsCtx(context.Background(), storageProcessor)
+}
+
+func (smis *SMIS) GetScsiEndpointsCtx(ctx context.Context, storageProcessor *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	return smis.AssociatorNamesCtx(ctx, storageProcessor, "", "CIM_SCSIProtocolEndpoint", nil, nil)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -249,14 +316,18 @@ func (smis *SMIS) GetScsiEndpoints(storageProcessor *gowbem.InstanceName) ([]gow
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetTargetEndpoints(systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	storageProcs, err := smis.GetStorageProcessorSystem(systemInstance)
+	return smis.GetTargetEndpointsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) GetTargetEndpointsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	storageProcs, err := smis.GetStorageProcessorSystemCtx(ctx, systemInstance)
 	if err != nil {
 		return nil, err
 	}
 
 	var frontEndPorts []gowbem.ObjectPath
 	for _, sp := range storageProcs {
-		adapter, err := smis.GetInstance(sp.InstancePath.InstanceName, false, nil)
+		adapter, err := smis.GetInstanceCtx(ctx, sp.InstancePath.InstanceName, false, nil)
 		if err != nil {
 			return nil, err
 		}
@@ -265,7 +336,7 @@ func (smis *SMIS) GetTargetEndpoints(systemInstance *gowbem.InstanceName) ([]gow
 			continue
 		}
 
-		ports, err := smis.GetScsiEndpoints(sp.InstancePath.InstanceName)
+		ports, err := smis.GetScsiEndpointsCtx(ctx, sp.InstancePath.InstanceName)
 		if err != nil {
 			return nil, err
 		}
@@ -281,27 +352,28 @@ func (smis *SMIS) GetTargetEndpoints(systemInstance *gowbem.InstanceName) ([]gow
 ///////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetVolumeByName(systemInstance *gowbem.InstanceName, volumeName string) ([]*gowbem.InstanceName, error) {
-	volumes, err := smis.GetVolumes(systemInstance)
+	return smis.GetVolumeByNameCtx(context.Background(), systemInstance, volumeName)
+}
+
+func (smis *SMIS) GetVolumeByNameCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volumeName string) ([]*gowbem.InstanceName, error) {
+	volumes, err := smis.AssociatorInstancesCtx(ctx, systemInstance, "", "CIM_StorageVolume", nil, nil, false, []string{"ElementName"})
 	if err != nil {
 		return nil, err
 	}
 
 	var foundVolumes []*gowbem.InstanceName
 	for _, volume := range volumes {
-		var volumeInstance *gowbem.Instance
-		volumeInstance, err = smis.GetInstance(volume.InstancePath.InstanceName, false, nil)
-		if err == nil {
-			nameProp, _ := GetPropertyByName(volumeInstance, "ElementName")
-			if nameProp == volumeName {
-				foundVolumes = append(foundVolumes, volume.InstancePath.InstanceName)
-				break
-			}
+		if volume.InstancePath == nil {
+			continue
+		}
+		if propertyString(volume.Instance, "ElementName") == volumeName {
+			foundVolumes = append(foundVolumes, volume.InstancePath.InstanceName)
 		}
 	}
 	if len(foundVolumes) > 0 {
 		return foundVolumes, nil
 	}
-	return nil, errors.New("Volume not found")
+	return nil, fmt.Errorf("Volume %w", ErrNotFound)
 }
 
 //////////////////////////////////////////////////////////////////////////////////////////////////
@@ -320,34 +392,33 @@ func (smis *SMIS) GetVolumeByName(systemInstance *gowbem.InstanceName, volumeNam
 //  12 - Query Pending: job is waiting for a client to resolve a query                          //
 //////////////////////////////////////////////////////////////////////////////////////////////////
 
+var jobStatusMap = map[JobState]string{
+	JobStateNew:          "NEW",
+	JobStateStarting:     "STARTING",
+	JobStateRunning:      "RUNNING",
+	JobStateSuspended:    "SUSPENDED",
+	JobStateShuttingDown: "SHUTTING_DOWN",
+	JobStateCompleted:    "COMPLETED",
+	JobStateTerminated:   "TERMINATED",
+	JobStateKilled:       "KILLED",
+	JobStateException:    "EXCEPTION",
+	JobStateService:      "SERVICE",
+	JobStateQueryPending: "QUERY_PENDING",
+}
+
 func (smis *SMIS) GetJobStatus(jobPath *gowbem.InstancePath) (*gowbem.Instance, string, error) {
-	resp, err := smis.GetInstance(jobPath.InstanceName, false, nil)
+	return smis.GetJobStatusCtx(context.Background(), jobPath)
+}
+
+func (smis *SMIS) GetJobStatusCtx(ctx context.Context, jobPath *gowbem.InstancePath) (*gowbem.Instance, string, error) {
+	resp, err := smis.GetInstanceCtx(ctx, jobPath.InstanceName, false, nil)
 	if err != nil {
 		return nil, "UNKNOWN", err
 	}
-	jobStatusMap := map[int]string{
-		2:  "NEW",
-		3:  "STARTING",
-		4:  "RUNNING",
-		5:  "SUSPENDED",
-		6:  "SHUTTING_DOWN",
-		7:  "COMPLETED",
-		8:  "TERMINATED",
-		9:  "KILLED",
-		10: "EXCEPTION",
-		11: "SERVICE",
-		12: "QUERY_PENDING",
-	}
-
-	var jobState int
-	var jobStatus string
-	var ok bool
+
 	value, _ := GetPropertyByName(resp, "JobState")
-	jobState, _ = strconv.Atoi(value.(string))
-	if jobStatus, ok = jobStatusMap[jobState]; !ok {
-		jobStatus = "UNKNOWN"
-	}
-	return resp, jobStatus, err
+	jobState, _ := strconv.Atoi(value.(string))
+	return resp, JobState(jobState).String(), err
 }
 
 ///////////////////////////////////////////////////////////////
@@ -361,41 +432,102 @@ func FindJobIndex(returnParams []gowbem.ParamValue) (int, error) {
 			return i, nil
 		}
 	}
-	return -1, errors.New("SE_ConcreteJob not found")
+	return -1, fmt.Errorf("SE_ConcreteJob %w", ErrNotFound)
 }
 
 func (smis *SMIS) WaitForJob(jobPath *gowbem.InstancePath, resultClass string) ([]gowbem.ObjectPath, error) {
-	var status string
-	var err error
+	return smis.WaitForJobCtx(context.Background(), jobPath, resultClass)
+}
+
+func (smis *SMIS) WaitForJobCtx(ctx context.Context, jobPath *gowbem.InstancePath, resultClass string) ([]gowbem.ObjectPath, error) {
+	return smis.WaitForJobWithCtx(ctx, smis.getJobWaiter(), jobPath, resultClass)
+}
 
-	for {
-		_, status, err = smis.GetJobStatus(jobPath)
+///////////////////////////////////////////////////////////////
+//     Outcome of an extrinsic method that may start a job   //
+///////////////////////////////////////////////////////////////
+
+type methodResult struct {
+	method      string
+	retValue    int
+	retParams   []gowbem.ParamValue
+	resultClass string
+}
+
+///////////////////////////////////////////////////////////////
+//      CHECK a method's return value and wait for its job   //
+//                                                           //
+//   0    - completed: returns the output references         //
+//   4096 - job started: waits for the job                   //
+//   else - returns a *MethodError                           //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) waitForMethodCtx(ctx context.Context, res *methodResult) ([]gowbem.ObjectPath, error) {
+	switch res.retValue {
+	case ReturnCompleted:
+		return outputReferences(res.retParams), nil
+	case ReturnJobStarted:
+		idx, err := FindJobIndex(res.retParams)
 		if err != nil {
 			return nil, err
 		}
-		if status != "RUNNING" {
-			break
-		}
-		time.Sleep(500000000)
-	}
-	if status != "COMPLETED" {
-		return nil, errors.New("Unexpected job status: " + status)
+		return smis.WaitForJobCtx(ctx, res.retParams[idx].ValueReference.InstancePath, res.resultClass)
 	}
+	return nil, NewMethodError(res.method, res.retValue)
+}
 
-	return smis.AssociatorNames(jobPath.InstanceName, "", resultClass, nil, nil)
+func outputReferences(params []gowbem.ParamValue) []gowbem.ObjectPath {
+	var paths []gowbem.ObjectPath
+	for _, param := range params {
+		if param.ValueReference != nil && param.ValueReference.InstancePath != nil {
+			paths = append(paths, gowbem.ObjectPath{InstancePath: param.ValueReference.InstancePath})
+		}
+		if param.ValueRefArray != nil {
+			for _, ref := range param.ValueRefArray.ValueReference {
+				if ref.InstancePath != nil {
+					paths = append(paths, gowbem.ObjectPath{InstancePath: ref.InstancePath})
+				}
+			}
+		}
+	}
+	return paths
 }
 
 //////////////////////////////////////
 //    REQUEST Structs used for      //
 //   volume creation on the VMAX3.  //
+//                                  //
+//  SLO, when set, is passed as the //
+//  Goal so the volumes are created //
+//  at that Service Level; it must  //
+//  be one of InPool's SLOs.        //
+//  EMCCollections lists storage    //
+//  groups to add the volumes to.   //
 //////////////////////////////////////
 
 type PostVolumesReq struct {
-	ElementName        string               `json:"ElementName"`
-	ElementType        string               `json:"ElementType"`
-	EMCNumberOfDevices string               `json:"EMCNumberOfDevices"`
-	InPool             *gowbem.InstanceName `json:"InPool"`
-	Size               string               `json:"Size"`
+	ElementName        string                `json:"ElementName"`
+	ElementType        string                `json:"ElementType"`
+	EMCNumberOfDevices string                `json:"EMCNumberOfDevices"`
+	InPool             *gowbem.InstanceName  `json:"InPool"`
+	Size               string                `json:"Size"`
+	SLO                *SLO_Struct           `json:"SLO,omitempty"`
+	EMCCollections     []gowbem.InstancePath `json:"EMCCollections,omitempty"`
+}
+
+// NewPostVolumesReq builds a request for count volumes of the given type
+// and size in bytes, rejecting element types the provider does not know.
+func NewPostVolumesReq(elementName string, elementType ElementType, count int, inPool *gowbem.InstanceName, size uint64) (*PostVolumesReq, error) {
+	if err := elementType.Validate(); err != nil {
+		return nil, err
+	}
+	return &PostVolumesReq{
+		ElementName:        elementName,
+		ElementType:        elementType.Value(),
+		EMCNumberOfDevices: strconv.Itoa(count),
+		InPool:             inPool,
+		Size:               strconv.FormatUint(size, 10),
+	}, nil
 }
 
 ///////////////////////////////////////////////////////////
@@ -404,7 +536,27 @@ type PostVolumesReq struct {
 ///////////////////////////////////////////////////////////
 
 func (smis *SMIS) PostVolumes(req *PostVolumesReq, systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	storage, err := smis.GetStorageConfigurationService(systemInstance)
+	return smis.PostVolumesCtx(context.Background(), req, systemInstance)
+}
+
+func (smis *SMIS) PostVolumesCtx(ctx context.Context, req *PostVolumesReq, systemInstance *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	res, err := smis.invokePostVolumesCtx(ctx, req, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	return smis.waitForMethodCtx(ctx, res)
+}
+
+func (smis *SMIS) invokePostVolumesCtx(ctx context.Context, req *PostVolumesReq, systemInstance *gowbem.InstanceName) (*methodResult, error) {
+	// An empty ElementType is sent as is, as it always was, and left to the
+	// provider; anything else must be a known type.
+	if req.ElementType != "" {
+		if _, err := ParseElementType(req.ElementType); err != nil {
+			return nil, err
+		}
+	}
+
+	storage, err := smis.GetStorageConfigurationServiceCtx(ctx, systemInstance)
 	if err != nil {
 		return nil, err
 	}
@@ -415,53 +567,98 @@ func (smis *SMIS) PostVolumes(req *PostVolumesReq, systemInstance *gowbem.Instan
 	params = append(params, gowbem.IParamValue{Name: "EMCNumberOfDevices", Value: &gowbem.Value{req.EMCNumberOfDevices}})
 	params = append(params, gowbem.IParamValue{Name: "InPool", ValueReference: &gowbem.ValueReference{InstanceName: req.InPool}})
 	params = append(params, gowbem.IParamValue{Name: "Size", Value: &gowbem.Value{req.Size}})
+	if req.SLO != nil {
+		goal, err := smis.GetSLOSettingCtx(ctx, req.InPool, req.SLO)
+		if err != nil {
+			return nil, err
+		}
+		params = append(params, gowbem.IParamValue{Name: "Goal", ValueReference: &gowbem.ValueReference{InstancePath: goal}})
+	}
+	if len(req.EMCCollections) > 0 {
+		var collections gowbem.ValueRefArray
+		collections.ValueReference = make([]gowbem.ValueReference, len(req.EMCCollections))
+		for idx := 0; idx < len(req.EMCCollections); idx++ {
+			collections.ValueReference[idx].InstancePath = &req.EMCCollections[idx]
+		}
+		params = append(params, gowbem.IParamValue{Name: "EMCCollections", ValueRefArray: &collections})
+	}
 
-	_, retValues, jobErr := smis.InvokeMethod(storage, "CreateOrModifyElementFromStoragePool", params)
+	retValue, retValues, jobErr := smis.InvokeMethodCtx(ctx, storage, "CreateOrModifyElementFromStoragePool", params)
 	if jobErr != nil {
 		return nil, jobErr
 	}
-
-	idx, _ := FindJobIndex(retValues)
-	if idx == -1 {
-		return nil, errors.New("Job instance not found")
-	}
-
-	return smis.WaitForJob(retValues[idx].ValueReference.InstancePath, "CIM_StorageVolume")
+	return &methodResult{"CreateOrModifyElementFromStoragePool", retValue, retValues, "CIM_StorageVolume"}, nil
 }
 
 ///////////////////////////////////////////////////////////////
 //                  CREATE an Array Group                    //
-//             groupType == 4 for storage Group              //
-//             groupType == 3 for port Group                 //
-//             groupType == 2 for initiator Group            //
 ///////////////////////////////////////////////////////////////
 
-func (smis *SMIS) PostCreateGroup(systemInstance *gowbem.InstanceName, groupName string, groupType int) (*gowbem.InstancePath, error) {
-	controller, err := smis.GetControllerConfigurationService(systemInstance)
+func (smis *SMIS) CreateGroup(systemInstance *gowbem.InstanceName, groupName string, groupType GroupType) (*gowbem.InstancePath, error) {
+	return smis.CreateGroupCtx(context.Background(), systemInstance, groupName, groupType)
+}
+
+func (smis *SMIS) CreateGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, groupName string, groupType GroupType) (*gowbem.InstancePath, error) {
+	return smis.createGroupCtx(ctx, systemInstance, groupName, groupType, nil)
+}
+
+// createGroupCtx invokes CreateGroup with any extra parameters, such as the
+// SLO of a storage group, after GroupName and Type.
+func (smis *SMIS) createGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, groupName string, groupType GroupType, extra []gowbem.IParamValue) (*gowbem.InstancePath, error) {
+	if err := groupType.Validate(); err != nil {
+		return nil, err
+	}
+
+	controller, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstance)
 	if err != nil {
 		return nil, err
 	}
 
 	var params []gowbem.IParamValue
 	params = append(params, gowbem.IParamValue{Name: "GroupName", Value: &gowbem.Value{groupName}})
-	params = append(params, gowbem.IParamValue{Name: "Type", Value: &gowbem.Value{strconv.Itoa(groupType)}})
+	params = append(params, gowbem.IParamValue{Name: "Type", Value: &gowbem.Value{strconv.Itoa(int(groupType))}})
+	params = append(params, extra...)
 
-	retValue, retParms, err := smis.InvokeMethod(controller, "CreateGroup", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, controller, "CreateGroup", params)
 	if err != nil {
 		return nil, err
 	}
-	if retValue != 0 || len(retParms) == 0 {
-		return nil, errors.New("Job failed, rc = " + string(retValue))
+	if retValue != 0 {
+		return nil, NewMethodError("CreateGroup", retValue)
+	}
+	if len(retParms) == 0 {
+		return nil, fmt.Errorf("MaskingGroup %w", ErrNotFound)
 	}
 	return retParms[0].ValueReference.InstancePath, nil
 }
 
+///////////////////////////////////////////////////////////////
+//                  CREATE an Array Group                    //
+//             groupType == 4 for storage Group              //
+//             groupType == 3 for port Group                 //
+//             groupType == 2 for initiator Group            //
+///////////////////////////////////////////////////////////////
+
+// Deprecated: use CreateGroup.
+func (smis *SMIS) PostCreateGroup(systemInstance *gowbem.InstanceName, groupName string, groupType int) (*gowbem.InstancePath, error) {
+	return smis.CreateGroupCtx(context.Background(), systemInstance, groupName, GroupType(groupType))
+}
+
+// Deprecated: use CreateGroupCtx.
+func (smis *SMIS) PostCreateGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, groupName string, groupType int) (*gowbem.InstancePath, error) {
+	return smis.CreateGroupCtx(ctx, systemInstance, groupName, GroupType(groupType))
+}
+
 ///////////////////////////////////////////////////////////////////
 //                GET Storage Pool Capabilities                  //
 ///////////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStoragePoolCapabilities(srp_name *gowbem.InstanceName) (*gowbem.InstanceName, error) {
-	capabilities, err := smis.EnumerateInstanceNames("Symm_StoragePoolCapabilities")
+	return smis.GetStoragePoolCapabilitiesCtx(context.Background(), srp_name)
+}
+
+func (smis *SMIS) GetStoragePoolCapabilitiesCtx(ctx context.Context, srp_name *gowbem.InstanceName) (*gowbem.InstanceName, error) {
+	capabilities, err := smis.EnumerateInstanceNamesCtx(ctx, "Symm_StoragePoolCapabilities")
 
 	name, err := GetKeyFromInstanceName(srp_name, "InstanceID")
 	if err != nil {
@@ -476,7 +673,7 @@ func (smis *SMIS) GetStoragePoolCapabilities(srp_name *gowbem.InstanceName) (*go
 			return &entry, nil
 		}
 	}
-	return nil, errors.New("Capabilities not found")
+	return nil, fmt.Errorf("Capabilities %w", ErrNotFound)
 
 }
 
@@ -485,11 +682,40 @@ func (smis *SMIS) GetStoragePoolCapabilities(srp_name *gowbem.InstanceName) (*go
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetStoragePoolSettings(srp_name *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
-	capabilities, err := smis.GetStoragePoolCapabilities(srp_name)
+	return smis.GetStoragePoolSettingsCtx(context.Background(), srp_name)
+}
+
+func (smis *SMIS) GetStoragePoolSettingsCtx(ctx context.Context, srp_name *gowbem.InstanceName) ([]gowbem.ObjectPath, error) {
+	capabilities, err := smis.GetStoragePoolCapabilitiesCtx(ctx, srp_name)
 	if err != nil {
 		return nil, err
 	}
-	return smis.AssociatorNames(capabilities, "", "CIM_StorageSetting", nil, nil)
+	return smis.AssociatorNamesCtx(ctx, capabilities, "", "CIM_StorageSetting", nil, nil)
+}
+
+///////////////////////////////////////////////////////////////
+//       GET the Storage Pool Setting matching an SLO        //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetSLOSetting(srp_name *gowbem.InstanceName, slo *SLO_Struct) (*gowbem.InstancePath, error) {
+	return smis.GetSLOSettingCtx(context.Background(), srp_name, slo)
+}
+
+func (smis *SMIS) GetSLOSettingCtx(ctx context.Context, srp_name *gowbem.InstanceName, slo *SLO_Struct) (*gowbem.InstancePath, error) {
+	settings, err := smis.GetStoragePoolSettingsCtx(ctx, srp_name)
+	if err != nil {
+		return nil, err
+	}
+	for _, setting := range settings {
+		id, err := GetKeyFromInstanceName(setting.InstancePath.InstanceName, "InstanceID")
+		if err != nil {
+			continue
+		}
+		if id.(string) == slo.InstanceID {
+			return setting.InstancePath, nil
+		}
+	}
+	return nil, fmt.Errorf("SLO %s %w", slo.ElementName, ErrNotFound)
 }
 
 ///////////////////////////////////////////////////////////////
@@ -514,17 +740,21 @@ type SLO_Struct struct {
 ////////////////////////////////////////////////////////////////
 
 func (smis *SMIS) GetSLOs(systemInstanceName *gowbem.InstanceName) (SLOs []SLO_Struct, err error) {
-	if !smis.IsArrayV3(systemInstanceName) {
+	return smis.GetSLOsCtx(context.Background(), systemInstanceName)
+}
+
+func (smis *SMIS) GetSLOsCtx(ctx context.Context, systemInstanceName *gowbem.InstanceName) (SLOs []SLO_Struct, err error) {
+	if !smis.IsArrayV3Ctx(ctx, systemInstanceName) {
 		return nil, errors.New("SLOs not supportted")
 	}
 
-	storagePools, err := smis.GetStoragePools(systemInstanceName)
+	storagePools, err := smis.GetStoragePoolsCtx(ctx, systemInstanceName)
 	if err != nil {
 		return nil, err
 	}
 
 	for _, SRP := range storagePools {
-		storagePoolSettings, err := smis.GetStoragePoolSettings(SRP.InstancePath.InstanceName)
+		storagePoolSettings, err := smis.GetStoragePoolSettingsCtx(ctx, SRP.InstancePath.InstanceName)
 		if err != nil {
 			return nil, err
 		}
@@ -554,10 +784,23 @@ func (smis *SMIS) GetSLOs(systemInstanceName *gowbem.InstanceName) (SLOs []SLO_S
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) AddMembersToGroup(systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) error {
-	controller, err := smis.GetControllerConfigurationService(systemInstance)
+	return smis.AddMembersToGroupCtx(context.Background(), systemInstance, group, members)
+}
+
+func (smis *SMIS) AddMembersToGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) error {
+	res, err := smis.invokeAddMembersToGroupCtx(ctx, systemInstance, group, members)
 	if err != nil {
 		return err
 	}
+	_, err = smis.waitForMethodCtx(ctx, res)
+	return err
+}
+
+func (smis *SMIS) invokeAddMembersToGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) (*methodResult, error) {
+	controller, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
 
 	var memberArray gowbem.ValueRefArray
 	memberArray.ValueReference = make([]gowbem.ValueReference, len(members))
@@ -569,14 +812,11 @@ func (smis *SMIS) AddMembersToGroup(systemInstance *gowbem.InstanceName, group *
 	params = append(params, gowbem.IParamValue{Name: "MaskingGroup", ValueReference: &gowbem.ValueReference{InstancePath: group}})
 	params = append(params, gowbem.IParamValue{Name: "Members", ValueRefArray: &memberArray})
 
-	retValue, retParms, err := smis.InvokeMethod(controller, "AddMembers", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, controller, "AddMembers", params)
 	if err != nil {
-		return err
-	}
-	if retValue != 0 {
-		_, err = smis.WaitForJob(retParms[0].ValueReference.InstancePath, group.InstanceName.ClassName)
+		return nil, err
 	}
-	return err
+	return &methodResult{"AddMembers", retValue, retParms, group.InstanceName.ClassName}, nil
 }
 
 ///////////////////////////////////////////////////////////////
@@ -584,7 +824,11 @@ func (smis *SMIS) AddMembersToGroup(systemInstance *gowbem.InstanceName, group *
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) RemoveMembersFromGroup(systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) error {
-	controller, err := smis.GetControllerConfigurationService(systemInstance)
+	return smis.RemoveMembersFromGroupCtx(context.Background(), systemInstance, group, members)
+}
+
+func (smis *SMIS) RemoveMembersFromGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) error {
+	controller, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstance)
 	if err != nil {
 		return err
 	}
@@ -599,52 +843,75 @@ func (smis *SMIS) RemoveMembersFromGroup(systemInstance *gowbem.InstanceName, gr
 	params = append(params, gowbem.IParamValue{Name: "MaskingGroup", ValueReference: &gowbem.ValueReference{InstancePath: group}})
 	params = append(params, gowbem.IParamValue{Name: "Members", ValueRefArray: &memberArray})
 
-	retValue, retParms, err := smis.InvokeMethod(controller, "RemoveMembers", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, controller, "RemoveMembers", params)
 	if err != nil {
 		return err
 	}
-	if retValue != 0 {
-		_, err = smis.WaitForJob(retParms[0].ValueReference.InstancePath, group.InstanceName.ClassName)
-	}
+	_, err = smis.waitForMethodCtx(ctx, &methodResult{"RemoveMembers", retValue, retParms, group.InstanceName.ClassName})
 	return err
 }
 
 ///////////////////////////////////////////////////////////////
 //          Create Storage Host Initiator                    //
-//     idType == 2 for WWN                                   //
-//     idType == 5 for IQN                                   //
 ///////////////////////////////////////////////////////////////
 
-func (smis *SMIS) PostStorageHardwareID(systemInstance *gowbem.InstanceName, storageID string, idType int) (*gowbem.InstancePath, error) {
-	management, err := smis.GetStorageHardwareIDManagementService(systemInstance)
-	if err != nil {
+func (smis *SMIS) CreateStorageHardwareID(systemInstance *gowbem.InstanceName, storageID string, idType HardwareIDType) (*gowbem.InstancePath, error) {
+	return smis.CreateStorageHardwareIDCtx(context.Background(), systemInstance, storageID, idType)
+}
+
+func (smis *SMIS) CreateStorageHardwareIDCtx(ctx context.Context, systemInstance *gowbem.InstanceName, storageID string, idType HardwareIDType) (*gowbem.InstancePath, error) {
+	if err := idType.Validate(); err != nil {
 		return nil, err
 	}
 
-	if idType != 2 && idType != 5 {
-		return nil, errors.New("Invalid type, must be 2 or 5")
+	management, err := smis.GetStorageHardwareIDManagementServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
 	}
 
 	var params []gowbem.IParamValue
 	params = append(params, gowbem.IParamValue{Name: "StorageID", Value: &gowbem.Value{storageID}})
-	params = append(params, gowbem.IParamValue{Name: "IDType", Value: &gowbem.Value{strconv.Itoa(idType)}})
+	params = append(params, gowbem.IParamValue{Name: "IDType", Value: &gowbem.Value{strconv.Itoa(int(idType))}})
 
-	retValue, retParms, err := smis.InvokeMethod(management, "CreateStorageHardwareID", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, management, "CreateStorageHardwareID", params)
 	if err != nil {
 		return nil, err
 	}
-	if retValue != 0 || len(retParms) == 0 {
-		return nil, errors.New("Job failed, rc = " + string(retValue))
+	if retValue != 0 {
+		return nil, NewMethodError("CreateStorageHardwareID", retValue)
+	}
+	if len(retParms) == 0 {
+		return nil, fmt.Errorf("HardwareID %w", ErrNotFound)
 	}
 	return retParms[0].ValueReference.InstancePath, nil
 }
 
+///////////////////////////////////////////////////////////////
+//          Create Storage Host Initiator                    //
+//     idType == 2 for WWN                                   //
+//     idType == 5 for IQN                                   //
+///////////////////////////////////////////////////////////////
+
+// Deprecated: use CreateStorageHardwareID.
+func (smis *SMIS) PostStorageHardwareID(systemInstance *gowbem.InstanceName, storageID string, idType int) (*gowbem.InstancePath, error) {
+	return smis.CreateStorageHardwareIDCtx(context.Background(), systemInstance, storageID, HardwareIDType(idType))
+}
+
+// Deprecated: use CreateStorageHardwareIDCtx.
+func (smis *SMIS) PostStorageHardwareIDCtx(ctx context.Context, systemInstance *gowbem.InstanceName, storageID string, idType int) (*gowbem.InstancePath, error) {
+	return smis.CreateStorageHardwareIDCtx(ctx, systemInstance, storageID, HardwareIDType(idType))
+}
+
 ///////////////////////////////////////////////////////////////
 //         Delete Initiator/Storage Hardware ID              //
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) DeleteStorageHardwareID(systemInstance *gowbem.InstanceName, hardwardId *gowbem.InstancePath) error {
-	management, err := smis.GetStorageHardwareIDManagementService(systemInstance)
+	return smis.DeleteStorageHardwareIDCtx(context.Background(), systemInstance, hardwardId)
+}
+
+func (smis *SMIS) DeleteStorageHardwareIDCtx(ctx context.Context, systemInstance *gowbem.InstanceName, hardwardId *gowbem.InstancePath) error {
+	management, err := smis.GetStorageHardwareIDManagementServiceCtx(ctx, systemInstance)
 	if err != nil {
 		return err
 	}
@@ -652,13 +919,11 @@ func (smis *SMIS) DeleteStorageHardwareID(systemInstance *gowbem.InstanceName, h
 	var params []gowbem.IParamValue
 	params = append(params, gowbem.IParamValue{Name: "HardwareID", ValueReference: &gowbem.ValueReference{InstancePath: hardwardId}})
 
-	retValue, retParms, err := smis.InvokeMethod(management, "DeleteStorageHardwareID", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, management, "DeleteStorageHardwareID", params)
 	if err != nil {
 		return err
 	}
-	if retValue != 0 {
-		_, err = smis.WaitForJob(retParms[0].ValueReference.InstancePath, hardwardId.InstanceName.ClassName)
-	}
+	_, err = smis.waitForMethodCtx(ctx, &methodResult{"DeleteStorageHardwareID", retValue, retParms, hardwardId.InstanceName.ClassName})
 	return err
 }
 
@@ -667,7 +932,19 @@ func (smis *SMIS) DeleteStorageHardwareID(systemInstance *gowbem.InstanceName, h
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) PostCreateMaskingView(systemInstance *gowbem.InstanceName, mvName string, sg, ig, pg *gowbem.InstancePath) ([]gowbem.ObjectPath, error) {
-	controller, err := smis.GetControllerConfigurationService(systemInstance)
+	return smis.PostCreateMaskingViewCtx(context.Background(), systemInstance, mvName, sg, ig, pg)
+}
+
+func (smis *SMIS) PostCreateMaskingViewCtx(ctx context.Context, systemInstance *gowbem.InstanceName, mvName string, sg, ig, pg *gowbem.InstancePath) ([]gowbem.ObjectPath, error) {
+	res, err := smis.invokeCreateMaskingViewCtx(ctx, systemInstance, mvName, sg, ig, pg)
+	if err != nil {
+		return nil, err
+	}
+	return smis.waitForMethodCtx(ctx, res)
+}
+
+func (smis *SMIS) invokeCreateMaskingViewCtx(ctx context.Context, systemInstance *gowbem.InstanceName, mvName string, sg, ig, pg *gowbem.InstancePath) (*methodResult, error) {
+	controller, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstance)
 	if err != nil {
 		return nil, err
 	}
@@ -678,17 +955,11 @@ func (smis *SMIS) PostCreateMaskingView(systemInstance *gowbem.InstanceName, mvN
 	params = append(params, gowbem.IParamValue{Name: "InitiatorMaskingGroup", ValueReference: &gowbem.ValueReference{InstancePath: ig}})
 	params = append(params, gowbem.IParamValue{Name: "TargetMaskingGroup", ValueReference: &gowbem.ValueReference{InstancePath: pg}})
 
-	_, retValues, err := smis.InvokeMethod(controller, "CreateMaskingView", params)
+	retValue, retValues, err := smis.InvokeMethodCtx(ctx, controller, "CreateMaskingView", params)
 	if err != nil {
 		return nil, err
 	}
-
-	idx, _ := FindJobIndex(retValues)
-	if idx == -1 {
-		return nil, errors.New("Job instance not found")
-	}
-
-	return smis.WaitForJob(retValues[idx].ValueReference.InstancePath, "Symm_LunMaskingView")
+	return &methodResult{"CreateMaskingView", retValue, retValues, MaskingViewClass}, nil
 }
 
 ////////////////////////////////////////////////////////////////
@@ -704,19 +975,20 @@ type HostAdapter struct {
 //             GET Baremetal HBA Information                  //
 ////////////////////////////////////////////////////////////////
 
+// GetBaremetalHBA lists the FC ports of this host.  As before hostv1, a
+// port whose sysfs attributes cannot be read is left out rather than
+// failing the list; only an unreadable fc_host directory is an error.
 func GetBaremetalHBA() (myHosts []HostAdapter, err error) {
-	//Works for RedHat 5 and above (including CentOS and SUSE Linux)
-	hostDir, _ := ioutil.ReadDir("/sys/class/scsi_host/")
-
-	for _, host := range hostDir {
-		if _, err := os.Stat("/sys/class/scsi_host/" + host.Name() + "/device/fc_host/" + host.Name() + "/port_name"); err == nil {
-			byteArray, _ := ioutil.ReadFile("/sys/class/scsi_host/" + host.Name() + "/device/fc_host/" + host.Name() + "/port_name")
-			newHost := HostAdapter{
-				HostID: host.Name(),
-				WWN:    string(byteArray),
-			}
-			myHosts = append(myHosts, newHost)
-		}
+	fcHosts, err := hostv1.New("").FCHosts()
+	var partial *hostv1.PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+	for _, fc := range fcHosts {
+		myHosts = append(myHosts, HostAdapter{
+			HostID: fc.HostID,
+			WWN:    fc.PortWWN,
+		})
 	}
 	return myHosts, nil
 }
@@ -727,7 +999,11 @@ func GetBaremetalHBA() (myHosts []HostAdapter, err error) {
 /////////////////////////////////////////////////////////////////
 
 func (smis *SMIS) PostDeleteGroup(systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, force bool) error {
-	controller, err := smis.GetControllerConfigurationService(systemInstance)
+	return smis.PostDeleteGroupCtx(context.Background(), systemInstance, group, force)
+}
+
+func (smis *SMIS) PostDeleteGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, force bool) error {
+	controller, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstance)
 	if err != nil {
 		return err
 	}
@@ -736,13 +1012,11 @@ func (smis *SMIS) PostDeleteGroup(systemInstance *gowbem.InstanceName, group *go
 	params = append(params, gowbem.IParamValue{Name: "MaskingGroup", ValueReference: &gowbem.ValueReference{InstancePath: group}})
 	params = append(params, gowbem.IParamValue{Name: "Force", Value: &gowbem.Value{strconv.FormatBool(force)}})
 
-	retValue, retParms, err := smis.InvokeMethod(controller, "DeleteGroup", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, controller, "DeleteGroup", params)
 	if err != nil {
 		return err
 	}
-	if retValue != 0 {
-		_, err = smis.WaitForJob(retParms[0].ValueReference.InstancePath, group.InstanceName.ClassName)
-	}
+	_, err = smis.waitForMethodCtx(ctx, &methodResult{"DeleteGroup", retValue, retParms, group.InstanceName.ClassName})
 	return err
 }
 
@@ -751,10 +1025,23 @@ func (smis *SMIS) PostDeleteGroup(systemInstance *gowbem.InstanceName, group *go
 /////////////////////////////////////////////////////////////////
 
 func (smis *SMIS) PostDeleteVol(systemInstance *gowbem.InstanceName, volumes []gowbem.InstancePath) error {
-	controller, err := smis.GetStorageConfigurationService(systemInstance)
+	return smis.PostDeleteVolCtx(context.Background(), systemInstance, volumes)
+}
+
+func (smis *SMIS) PostDeleteVolCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volumes []gowbem.InstancePath) error {
+	res, err := smis.invokeDeleteVolCtx(ctx, systemInstance, volumes)
 	if err != nil {
 		return err
 	}
+	_, err = smis.waitForMethodCtx(ctx, res)
+	return err
+}
+
+func (smis *SMIS) invokeDeleteVolCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volumes []gowbem.InstancePath) (*methodResult, error) {
+	controller, err := smis.GetStorageConfigurationServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
 
 	var volumeArray gowbem.ValueRefArray
 	volumeArray.ValueReference = make([]gowbem.ValueReference, len(volumes))
@@ -765,14 +1052,11 @@ func (smis *SMIS) PostDeleteVol(systemInstance *gowbem.InstanceName, volumes []g
 	var params []gowbem.IParamValue
 	params = append(params, gowbem.IParamValue{Name: "TheElements", ValueRefArray: &volumeArray})
 
-	retValue, retParms, err := smis.InvokeMethod(controller, "ReturnElementsToStoragePool", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, controller, "ReturnElementsToStoragePool", params)
 	if err != nil {
-		return err
-	}
-	if retValue != 0 {
-		_, err = smis.WaitForJob(retParms[0].ValueReference.InstancePath, "Symm_StorageVolume")
+		return nil, err
 	}
-	return err
+	return &methodResult{"ReturnElementsToStoragePool", retValue, retParms, "Symm_StorageVolume"}, nil
 }
 
 /////////////////////////////////////////////////////////////////
@@ -780,7 +1064,11 @@ func (smis *SMIS) PostDeleteVol(systemInstance *gowbem.InstanceName, volumes []g
 /////////////////////////////////////////////////////////////////
 
 func (smis *SMIS) PostDeleteMaskingView(systemInstance *gowbem.InstanceName, maskingView *gowbem.InstancePath) error {
-	controller, err := smis.GetControllerConfigurationService(systemInstance)
+	return smis.PostDeleteMaskingViewCtx(context.Background(), systemInstance, maskingView)
+}
+
+func (smis *SMIS) PostDeleteMaskingViewCtx(ctx context.Context, systemInstance *gowbem.InstanceName, maskingView *gowbem.InstancePath) error {
+	controller, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstance)
 	if err != nil {
 		return err
 	}
@@ -788,13 +1076,11 @@ func (smis *SMIS) PostDeleteMaskingView(systemInstance *gowbem.InstanceName, mas
 	var params []gowbem.IParamValue
 	params = append(params, gowbem.IParamValue{Name: "ProtocolController", ValueReference: &gowbem.ValueReference{InstancePath: maskingView}})
 
-	retValue, retParms, err := smis.InvokeMethod(controller, "DeleteMaskingView", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, controller, "DeleteMaskingView", params)
 	if err != nil {
 		return err
 	}
-	if retValue != 0 {
-		_, err = smis.WaitForJob(retParms[0].ValueReference.InstancePath, "Symm_LunMaskingView")
-	}
+	_, err = smis.waitForMethodCtx(ctx, &methodResult{"DeleteMaskingView", retValue, retParms, MaskingViewClass})
 	return err
 
 }
@@ -815,37 +1101,59 @@ type PortValues struct {
 ///////////////////////////////////////////////////////////////
 
 func (smis *SMIS) PostPortLogins(systemInstance *gowbem.InstanceName, initiator *gowbem.InstancePath) ([]PortValues, error) {
+	return smis.PostPortLoginsCtx(context.Background(), systemInstance, initiator)
+}
+
+func (smis *SMIS) PostPortLoginsCtx(ctx context.Context, systemInstance *gowbem.InstanceName, initiator *gowbem.InstancePath) ([]PortValues, error) {
 
-	service, err := smis.GetStorageHardwareIDManagementService(systemInstance)
+	service, err := smis.GetStorageHardwareIDManagementServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := smis.GetInstanceCtx(ctx, initiator.InstanceName, false, []string{"StorageID"})
 	if err != nil {
 		return nil, err
 	}
+	return smis.portLoginsCtx(ctx, service, initiator, propertyString(instance, "StorageID"))
+}
 
+// portLoginsCtx asks the hardware ID management service for the ports an
+// initiator is logged in to.  storageID is the initiator's WWN or IQN.
+func (smis *SMIS) portLoginsCtx(ctx context.Context, service *gowbem.InstanceName, initiator *gowbem.InstancePath, storageID string) ([]PortValues, error) {
 	var params []gowbem.IParamValue
 	params = append(params, gowbem.IParamValue{Name: "HardwareID", ValueReference: &gowbem.ValueReference{InstancePath: initiator}})
 
-	retValue, retParms, err := smis.InvokeMethod(service, "EMCGetTargetEndpoints", params)
+	retValue, retParms, err := smis.InvokeMethodCtx(ctx, service, "EMCGetTargetEndpoints", params)
 	if err != nil {
 		return nil, err
 	}
 	if retValue != 0 {
-		return nil, errors.New("Job failed, rc = " + strconv.Itoa(retValue))
+		return nil, NewMethodError("EMCGetTargetEndpoints", retValue)
+	}
+
+	if len(retParms) == 0 || retParms[0].ValueRefArray == nil {
+		return nil, nil
 	}
 
 	var portValues []PortValues
 	for _, ref := range retParms[0].ValueRefArray.ValueReference {
-		wwn, _ := GetKeyFromInstanceName(initiator.InstanceName, "InstanceID")
-		wwnSplit := strings.Split(wwn.(string), "-+-")
-
-		eSystemName, _ := GetKeyFromInstanceName(ref.InstancePath.InstanceName, "SystemName")
-		eSystemNameSplit := strings.Split(eSystemName.(string), "-+-")
-		PortAndDirector := strings.Split(eSystemNameSplit[2], "-")
+		if ref.InstancePath == nil {
+			continue
+		}
+		eSystemName, err := keyString(ref.InstancePath.InstanceName, "SystemName")
+		if err != nil {
+			return nil, err
+		}
+		director, port, err := ParseFrontEndPortName(eSystemName)
+		if err != nil {
+			return nil, err
+		}
 		PV := PortValues{
-			WWN:        wwnSplit[1],
-			PortNumber: PortAndDirector[0],
-			Director:   PortAndDirector[1],
+			WWN:        storageID,
+			PortNumber: port,
+			Director:   director,
 		}
 		portValues = append(portValues, PV)
 	}
-	return portValues, err
+	return portValues, nil
 }
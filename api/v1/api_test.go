@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
 )
 
 var smis *SMIS
@@ -288,6 +289,33 @@ func TestGetInitiators(t *testing.T) {
 	}
 }
 
+func TestGetVolumesCtx(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	vols, err := smis.GetVolumesCtx(ctx, testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	for _, entry := range vols {
+		DumpInstanceClass(entry.InstancePath.InstanceName)
+	}
+}
+
+func TestCanceledCtx(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := smis.GetStorageArraysCtx(ctx)
+	if err != context.Canceled {
+		t.Log("expected context.Canceled, got", err)
+		t.Fail()
+	}
+}
+
 func TestGetVolumeByID(t *testing.T) {
 
 	var volumeId interface{}
@@ -531,8 +559,13 @@ func TestPostPortLogins(t *testing.T) {
 			t.Fail()
 			return
 		}
-		for i := 0; i < len(portValues); i++ {
-			fmt.Println("Port Number:" + portValues[i].PortNumber + " Director:" + portValues[i].Director + " WWN:" + portValues[i].WWN)
+		for _, pv := range portValues {
+			// Director is the full name such as FA-1D, and the WWN has no
+			// InstanceID prefix.
+			if !strings.Contains(pv.Director, "-") || !isDigits(pv.PortNumber) || strings.Contains(pv.WWN, keySeparator) || pv.WWN == "" {
+				t.Log("unexpected port login", pv)
+				t.Fail()
+			}
 		}
 	}
 }
@@ -648,3 +681,52 @@ func TestPostCreateMaskingView(t *testing.T) {
 		}
 	}
 }
+
+func TestPostVolumesWithSLO(t *testing.T) {
+	if !smis.IsArrayV3(testingInstance) {
+		return
+	}
+	SLOs, err := smis.GetSLOs(testingInstance)
+	if err != nil || len(SLOs) == 0 {
+		t.Log("no SLOs found", err)
+		t.Fail()
+		return
+	}
+
+	pools, _ := smis.GetStoragePools(testingInstance)
+	var pool *gowbem.InstanceName
+	for _, p := range pools {
+		id, _ := GetKeyFromInstanceName(p.InstancePath.InstanceName, "InstanceID")
+		if strings.HasSuffix(id.(string), SLOs[0].SRP) {
+			pool = p.InstancePath.InstanceName
+		}
+	}
+	if pool == nil {
+		t.Log("SRP not found: " + SLOs[0].SRP)
+		t.Fail()
+		return
+	}
+
+	volumes, err := smis.PostVolumes(&PostVolumesReq{
+		ElementName:        "govmax_test_slo",
+		ElementType:        "2",
+		EMCNumberOfDevices: "1",
+		Size:               "123",
+		InPool:             pool,
+		SLO:                &SLOs[0],
+	}, testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	var volPaths []gowbem.InstancePath
+	for _, p := range volumes {
+		volPaths = append(volPaths, *p.InstancePath)
+	}
+	if err = smis.PostDeleteVol(testingInstance, volPaths); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+}
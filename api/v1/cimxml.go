@@ -0,0 +1,540 @@
+package apiv1
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+// CIM operations are sent as raw CIM-XML over the SMIS http.Client rather
+// than through the gowbem connection, whose calls cannot be cancelled.  The
+// gowbem types are still used for the decoded names and instances.
+
+const smisNamespace = "root/emc"
+
+var cimMessageID uint64 = 1000
+
+////////////////////////////////////////////////////////////////
+//            Error returned in a CIM-XML response            //
+////////////////////////////////////////////////////////////////
+
+type CIMError struct {
+	Code        int    `xml:"CODE,attr"`
+	Description string `xml:"DESCRIPTION,attr"`
+}
+
+// CIM status codes from DSP0200.
+const (
+	CIMErrFailed                    = 1
+	CIMErrNotFound                  = 6
+	CIMErrNotSupported              = 7
+	CIMErrAlreadyExists             = 11
+	CIMErrMethodNotAvailable        = 16
+	CIMErrInvalidEnumerationContext = 21
+	CIMErrServerLimitsExceeded      = 27
+	CIMErrServerIsShuttingDown      = 28
+)
+
+func (e *CIMError) Error() string {
+	return "CIM error " + strconv.Itoa(e.Code) + ": " + e.Description
+}
+
+////////////////////////////////////////////////////////////////
+//       An instance together with its full instance path     //
+////////////////////////////////////////////////////////////////
+
+type InstanceWithPath struct {
+	InstancePath *gowbem.InstancePath `xml:"INSTANCEPATH"`
+	Instance     *gowbem.Instance     `xml:"INSTANCE"`
+}
+
+////////////////////////////////////////////////////////////////
+//     An association instance returned by References         //
+////////////////////////////////////////////////////////////////
+
+type cimProperty struct {
+	Name  string `xml:"NAME,attr"`
+	Value string `xml:"VALUE"`
+}
+
+type cimPropertyReference struct {
+	Name         string               `xml:"NAME,attr"`
+	InstancePath *gowbem.InstancePath `xml:"VALUE.REFERENCE>INSTANCEPATH"`
+	InstanceName *gowbem.InstanceName `xml:"VALUE.REFERENCE>INSTANCENAME"`
+}
+
+type Association struct {
+	InstancePath *gowbem.InstancePath   `xml:"INSTANCEPATH"`
+	Properties   []cimProperty          `xml:"INSTANCE>PROPERTY"`
+	References   []cimPropertyReference `xml:"INSTANCE>PROPERTY.REFERENCE"`
+}
+
+// Property returns the value of a plain property, or "" if it is not set.
+func (a *Association) Property(name string) string {
+	for _, p := range a.Properties {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// Reference returns the instance named by a reference property such as
+// Antecedent or Dependent.
+func (a *Association) Reference(name string) (*gowbem.InstanceName, error) {
+	for _, r := range a.References {
+		if r.Name != name {
+			continue
+		}
+		if r.InstancePath != nil && r.InstancePath.InstanceName != nil {
+			return r.InstancePath.InstanceName, nil
+		}
+		if r.InstanceName != nil {
+			return r.InstanceName, nil
+		}
+	}
+	return nil, fmt.Errorf("Reference %s %w", name, ErrNotFound)
+}
+
+type cimParamValue struct {
+	Name  string `xml:"NAME,attr"`
+	Value string `xml:"VALUE"`
+}
+
+// cimIReturnValue keeps the IRETURNVALUE undecoded; each operation decodes
+// the elements it expects with decodeReturn.
+type cimIReturnValue struct {
+	XML []byte `xml:",innerxml"`
+}
+
+type cimIMethodResponse struct {
+	Name        string          `xml:"NAME,attr"`
+	Error       *CIMError       `xml:"ERROR"`
+	ReturnValue cimIReturnValue `xml:"IRETURNVALUE"`
+	ParamValues []cimParamValue `xml:"PARAMVALUE"`
+}
+
+// cimReference is a VALUE.REFERENCE; the provider sends either a full
+// INSTANCEPATH or a namespace relative INSTANCENAME.
+type cimReference struct {
+	InstancePath *gowbem.InstancePath `xml:"INSTANCEPATH"`
+	InstanceName *gowbem.InstanceName `xml:"INSTANCENAME"`
+}
+
+func (r *cimReference) valueReference() *gowbem.ValueReference {
+	path := r.InstancePath
+	if path == nil {
+		path = &gowbem.InstancePath{InstanceName: r.InstanceName}
+	}
+	return &gowbem.ValueReference{InstancePath: path, InstanceName: path.InstanceName}
+}
+
+type cimMethodParamValue struct {
+	Name      string         `xml:"NAME,attr"`
+	Value     *string        `xml:"VALUE"`
+	Reference *cimReference  `xml:"VALUE.REFERENCE"`
+	RefArray  []cimReference `xml:"VALUE.REFARRAY>VALUE.REFERENCE"`
+}
+
+type cimMethodResponse struct {
+	Name        string                `xml:"NAME,attr"`
+	Error       *CIMError             `xml:"ERROR"`
+	ReturnValue string                `xml:"RETURNVALUE>VALUE"`
+	ParamValues []cimMethodParamValue `xml:"PARAMVALUE"`
+}
+
+type cimResponse struct {
+	XMLName        xml.Name           `xml:"CIM"`
+	Response       cimIMethodResponse `xml:"MESSAGE>SIMPLERSP>IMETHODRESPONSE"`
+	MethodResponse cimMethodResponse  `xml:"MESSAGE>SIMPLERSP>METHODRESPONSE"`
+}
+
+func (r *cimIMethodResponse) paramValue(name string) string {
+	for _, p := range r.ParamValues {
+		if p.Name == name {
+			return p.Value
+		}
+	}
+	return ""
+}
+
+// decodeReturn unmarshals the content of the IRETURNVALUE into v, whose
+// field tags name the elements under IRETURNVALUE.
+func (r *cimIMethodResponse) decodeReturn(v interface{}) error {
+	data := make([]byte, 0, len(r.ReturnValue.XML)+32)
+	data = append(data, "<IRETURNVALUE>"...)
+	data = append(data, r.ReturnValue.XML...)
+	data = append(data, "</IRETURNVALUE>"...)
+	return xml.Unmarshal(data, v)
+}
+
+// cimIParam is an IPARAMVALUE whose content is already CIM-XML encoded.
+type cimIParam struct {
+	Name string
+	XML  string
+}
+
+/////////////////////////
+// CIM-XML encoding    //
+/////////////////////////
+
+func xmlEscape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func xmlValue(value string) string {
+	return "<VALUE>" + xmlEscape(value) + "</VALUE>"
+}
+
+func xmlValueArray(values []string) string {
+	s := "<VALUE.ARRAY>"
+	for _, v := range values {
+		s += xmlValue(v)
+	}
+	return s + "</VALUE.ARRAY>"
+}
+
+func xmlClassName(name string) string {
+	return `<CLASSNAME NAME="` + xmlEscape(name) + `"/>`
+}
+
+// xmlInstanceName encodes an instance name.  gowbem.KeyValue keeps only the
+// text of a key, not its VALUETYPE, so every key is sent as a string.
+func xmlInstanceName(instanceName *gowbem.InstanceName) string {
+	s := `<INSTANCENAME CLASSNAME="` + xmlEscape(instanceName.ClassName) + `">`
+	for _, key := range instanceName.KeyBinding {
+		s += `<KEYBINDING NAME="` + xmlEscape(key.Name) + `">`
+		s += `<KEYVALUE VALUETYPE="string">` + xmlEscape(key.KeyValue.KeyValue) + `</KEYVALUE>`
+		s += `</KEYBINDING>`
+	}
+	return s + "</INSTANCENAME>"
+}
+
+// cimType returns the CIM type of a property value and its text form.
+// Only the Go types with a CIM equivalent are accepted.
+func cimType(value interface{}) (string, string, error) {
+	switch v := value.(type) {
+	case string:
+		return "string", v, nil
+	case bool:
+		return "boolean", strconv.FormatBool(v), nil
+	case uint8:
+		return "uint8", strconv.FormatUint(uint64(v), 10), nil
+	case uint16:
+		return "uint16", strconv.FormatUint(uint64(v), 10), nil
+	case uint32:
+		return "uint32", strconv.FormatUint(uint64(v), 10), nil
+	case uint64:
+		return "uint64", strconv.FormatUint(v, 10), nil
+	case int8:
+		return "sint8", strconv.FormatInt(int64(v), 10), nil
+	case int16:
+		return "sint16", strconv.FormatInt(int64(v), 10), nil
+	case int32:
+		return "sint32", strconv.FormatInt(int64(v), 10), nil
+	case int64:
+		return "sint64", strconv.FormatInt(v, 10), nil
+	}
+	return "", "", fmt.Errorf("no CIM type for %T", value)
+}
+
+// xmlInstance encodes properties with the CIM type of their Go value, so a
+// uint16 property is sent as TYPE="uint16" and a bool as TYPE="boolean".
+func xmlInstance(className string, properties map[string]interface{}) (string, error) {
+	names := make([]string, 0, len(properties))
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := `<INSTANCE CLASSNAME="` + xmlEscape(className) + `">`
+	for _, name := range names {
+		valueType, value, err := cimType(properties[name])
+		if err != nil {
+			return "", errors.New("Property " + name + ": " + err.Error())
+		}
+		s += `<PROPERTY NAME="` + xmlEscape(name) + `" TYPE="` + valueType + `">` + xmlValue(value) + `</PROPERTY>`
+	}
+	return s + "</INSTANCE>", nil
+}
+
+func xmlLocalNamespacePath(namespace string) string {
+	s := "<LOCALNAMESPACEPATH>"
+	for _, ns := range strings.Split(namespace, "/") {
+		if ns != "" {
+			s += `<NAMESPACE NAME="` + xmlEscape(ns) + `"/>`
+		}
+	}
+	return s + "</LOCALNAMESPACEPATH>"
+}
+
+func xmlLocalInstancePath(instanceName *gowbem.InstanceName) string {
+	return "<LOCALINSTANCEPATH>" + xmlLocalNamespacePath(smisNamespace) +
+		xmlInstanceName(instanceName) + "</LOCALINSTANCEPATH>"
+}
+
+// xmlReference encodes a reference relative to the SMI-S namespace, which
+// is where every instance this package hands out lives.
+func xmlReference(ref *gowbem.ValueReference) string {
+	name := ref.InstanceName
+	if ref.InstancePath != nil {
+		name = ref.InstancePath.InstanceName
+	}
+	return "<VALUE.REFERENCE>" + xmlLocalInstancePath(name) + "</VALUE.REFERENCE>"
+}
+
+// xmlParamValue encodes an extrinsic method parameter.  Plain values carry
+// no PARAMTYPE; the provider converts them to the declared type.
+func xmlParamValue(param *gowbem.IParamValue) string {
+	s := `<PARAMVALUE NAME="` + xmlEscape(param.Name) + `"`
+	switch {
+	case param.ValueReference != nil:
+		s += ` PARAMTYPE="reference">` + xmlReference(param.ValueReference)
+	case param.ValueRefArray != nil:
+		s += ` PARAMTYPE="reference"><VALUE.REFARRAY>`
+		for idx := range param.ValueRefArray.ValueReference {
+			s += xmlReference(&param.ValueRefArray.ValueReference[idx])
+		}
+		s += "</VALUE.REFARRAY>"
+	case param.Value != nil:
+		s += ">" + xmlValue(param.Value.Value)
+	default:
+		s += ">"
+	}
+	return s + "</PARAMVALUE>"
+}
+
+// cimObjectHeader is the CIMObject header of an extrinsic call, the
+// untyped model path of the instance the method is invoked on.
+func cimObjectHeader(instanceName *gowbem.InstanceName) string {
+	var keys []string
+	for _, key := range instanceName.KeyBinding {
+		keys = append(keys, key.Name+`="`+key.KeyValue.KeyValue+`"`)
+	}
+	return url.QueryEscape(smisNamespace + ":" + instanceName.ClassName + "." + strings.Join(keys, ","))
+}
+
+//////////////////
+// getCimomUrl  //
+//////////////////
+
+func getCimomUrl(smis *SMIS) string {
+	var schema string
+	if smis.insecure {
+		schema = "http"
+	} else {
+		schema = "https"
+	}
+	path := url.URL{
+		Scheme: schema,
+		Host:   smis.host + ":" + smis.port,
+		Path:   "/cimom",
+	}
+	return path.String()
+}
+
+////////////////////
+// postCimXMLCtx  //
+////////////////////
+
+// postCimXMLCtx wraps call in a CIM message and posts it.  The request
+// carries ctx, so cancelling ctx closes the connection of a call still in
+// flight rather than leaving it to finish in the background.
+func (smis *SMIS) postCimXMLCtx(ctx context.Context, method, cimObject, call string) (*cimResponse, error) {
+	id := atomic.AddUint64(&cimMessageID, 1)
+
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0" encoding="utf-8" ?>`)
+	body.WriteString(`<CIM CIMVERSION="2.0" DTDVERSION="2.0">`)
+	body.WriteString(`<MESSAGE ID="` + strconv.FormatUint(id, 10) + `" PROTOCOLVERSION="1.0">`)
+	body.WriteString(`<SIMPLEREQ>` + call + `</SIMPLEREQ></MESSAGE></CIM>`)
+
+	req, err := http.NewRequest("POST", getCimomUrl(smis), &body)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.SetBasicAuth(smis.username, smis.password)
+	req.Header.Set("Content-Type", `application/xml; charset="utf-8"`)
+	req.Header.Set("CIMOperation", "MethodCall")
+	req.Header.Set("CIMMethod", method)
+	req.Header.Set("CIMObject", cimObject)
+
+	resp, err := smis.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		if cimErr := resp.Header.Get("CIMError"); cimErr != "" {
+			return nil, errors.New(method + ": " + resp.Status + ": " + cimErr)
+		}
+		return nil, errors.New(method + ": " + resp.Status)
+	}
+
+	var cimResp cimResponse
+	if err = xml.Unmarshal(data, &cimResp); err != nil {
+		return nil, err
+	}
+	return &cimResp, nil
+}
+
+/////////////////////////
+// invokeIntrinsicCtx  //
+/////////////////////////
+
+// cimIntrinsicCallXML encodes the IMETHODCALL of an intrinsic method.
+func cimIntrinsicCallXML(method string, params []cimIParam) string {
+	s := `<IMETHODCALL NAME="` + method + `">` + xmlLocalNamespacePath(smisNamespace)
+	for _, p := range params {
+		s += `<IPARAMVALUE NAME="` + p.Name + `">` + p.XML + `</IPARAMVALUE>`
+	}
+	return s + "</IMETHODCALL>"
+}
+
+// invokeIntrinsicCtx sends a single intrinsic method call and decodes the
+// IMETHODRESPONSE.  A CIM ERROR in the response is returned as a *CIMError.
+func (smis *SMIS) invokeIntrinsicCtx(ctx context.Context, method string, params []cimIParam) (*cimIMethodResponse, error) {
+	cimResp, err := smis.postCimXMLCtx(ctx, method, url.QueryEscape(smisNamespace), cimIntrinsicCallXML(method, params))
+	if err != nil {
+		return nil, err
+	}
+	if cimResp.Response.Error != nil {
+		return nil, cimResp.Response.Error
+	}
+	return &cimResp.Response, nil
+}
+
+/////////////////////////
+// invokeExtrinsicCtx  //
+/////////////////////////
+
+// cimMethodCallXML encodes the METHODCALL of an extrinsic method.
+func cimMethodCallXML(instanceName *gowbem.InstanceName, method string, params []gowbem.IParamValue) string {
+	s := `<METHODCALL NAME="` + xmlEscape(method) + `">` + xmlLocalInstancePath(instanceName)
+	for idx := range params {
+		s += xmlParamValue(&params[idx])
+	}
+	return s + "</METHODCALL>"
+}
+
+// invokeExtrinsicCtx invokes a method of an instance and returns its return
+// value and output parameters in the form gowbem uses.
+func (smis *SMIS) invokeExtrinsicCtx(ctx context.Context, instanceName *gowbem.InstanceName, method string, params []gowbem.IParamValue) (int, []gowbem.ParamValue, error) {
+	cimResp, err := smis.postCimXMLCtx(ctx, method, cimObjectHeader(instanceName), cimMethodCallXML(instanceName, method, params))
+	if err != nil {
+		return -1, nil, err
+	}
+	resp := &cimResp.MethodResponse
+	if resp.Error != nil {
+		return -1, nil, resp.Error
+	}
+	retValue, err := strconv.Atoi(strings.TrimSpace(resp.ReturnValue))
+	if err != nil {
+		return -1, nil, errors.New(method + ": invalid return value " + resp.ReturnValue)
+	}
+
+	var out []gowbem.ParamValue
+	for _, p := range resp.ParamValues {
+		param := gowbem.ParamValue{Name: p.Name}
+		switch {
+		case p.Reference != nil:
+			param.ValueReference = p.Reference.valueReference()
+		case p.RefArray != nil:
+			param.ValueRefArray = &gowbem.ValueRefArray{}
+			for idx := range p.RefArray {
+				param.ValueRefArray.ValueReference = append(param.ValueRefArray.ValueReference, *p.RefArray[idx].valueReference())
+			}
+		case p.Value != nil:
+			param.Value = &gowbem.Value{*p.Value}
+		}
+		out = append(out, param)
+	}
+	return retValue, out, nil
+}
+
+////////////////////
+// ModifyInstance //
+////////////////////
+
+// ModifyInstance sets the given properties on an instance; properties that
+// are not listed are left unchanged.  Each value's Go type gives the CIM type
+// it is sent as: string, bool, or a sized integer such as uint16.
+func (smis *SMIS) ModifyInstance(instanceName *gowbem.InstanceName, properties map[string]interface{}) error {
+	return smis.ModifyInstanceCtx(context.Background(), instanceName, properties)
+}
+
+func (smis *SMIS) ModifyInstanceCtx(ctx context.Context, instanceName *gowbem.InstanceName, properties map[string]interface{}) error {
+	if len(properties) == 0 {
+		return errors.New("ModifyInstance: no properties to modify")
+	}
+
+	var propertyList []string
+	for name := range properties {
+		propertyList = append(propertyList, name)
+	}
+	sort.Strings(propertyList)
+
+	instance, err := xmlInstance(instanceName.ClassName, properties)
+	if err != nil {
+		return err
+	}
+	namedInstance := "<VALUE.NAMEDINSTANCE>" + xmlInstanceName(instanceName) + instance + "</VALUE.NAMEDINSTANCE>"
+	params := []cimIParam{
+		{Name: "ModifiedInstance", XML: namedInstance},
+		{Name: "IncludeQualifiers", XML: xmlValue("false")},
+		{Name: "PropertyList", XML: xmlValueArray(propertyList)},
+	}
+	_, err = smis.invokeIntrinsicCtx(ctx, "ModifyInstance", params)
+	return err
+}
+
+////////////////
+// References //
+////////////////
+
+// References returns the association instances of assocClass that refer to
+// instanceName in the given role ("" for any role).
+func (smis *SMIS) References(instanceName *gowbem.InstanceName, assocClass, role string) ([]Association, error) {
+	return smis.ReferencesCtx(context.Background(), instanceName, assocClass, role)
+}
+
+func (smis *SMIS) ReferencesCtx(ctx context.Context, instanceName *gowbem.InstanceName, assocClass, role string) ([]Association, error) {
+	params := []cimIParam{{Name: "ObjectName", XML: xmlInstanceName(instanceName)}}
+	if assocClass != "" {
+		params = append(params, cimIParam{Name: "ResultClass", XML: xmlClassName(assocClass)})
+	}
+	if role != "" {
+		params = append(params, cimIParam{Name: "Role", XML: xmlValue(role)})
+	}
+
+	resp, err := smis.invokeIntrinsicCtx(ctx, "References", params)
+	if err != nil {
+		return nil, err
+	}
+	var ret struct {
+		Associations []Association `xml:"VALUE.OBJECTWITHPATH"`
+	}
+	if err = resp.decodeReturn(&ret); err != nil {
+		return nil, err
+	}
+	return ret.Associations, nil
+}
@@ -0,0 +1,166 @@
+package apiv1
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+// fakeCIMOM serves every CIM-XML request with handler and returns an SMIS
+// talking to it over http.
+func fakeCIMOM(t *testing.T, handler http.HandlerFunc) (*SMIS, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	u, _ := url.Parse(server.URL)
+	host, port := u.Hostname(), u.Port()
+	client, err := New(host, port, true, "admin", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, server
+}
+
+func cimMessage(body string) string {
+	return `<?xml version="1.0" encoding="utf-8" ?><CIM CIMVERSION="2.0" DTDVERSION="2.0"><MESSAGE ID="1" PROTOCOLVERSION="1.0"><SIMPLERSP>` +
+		body + `</SIMPLERSP></MESSAGE></CIM>`
+}
+
+var testVolumeName = &gowbem.InstanceName{
+	ClassName: "Symm_StorageVolume",
+	KeyBinding: []gowbem.KeyBinding{
+		{Name: "DeviceID", KeyValue: &gowbem.KeyValue{"00ABC"}},
+		{Name: "SystemName", KeyValue: &gowbem.KeyValue{"SYMMETRIX-+-000196701380"}},
+	},
+}
+
+func TestCimMethodCallXML(t *testing.T) {
+	params := []gowbem.IParamValue{
+		{Name: "ElementName", Value: &gowbem.Value{"vol<1>"}},
+		{Name: "TheElement", ValueReference: &gowbem.ValueReference{InstancePath: &gowbem.InstancePath{InstanceName: testVolumeName}}},
+		{Name: "Members", ValueRefArray: &gowbem.ValueRefArray{ValueReference: []gowbem.ValueReference{{InstanceName: testVolumeName}}}},
+	}
+	call := cimMethodCallXML(testVolumeName, "ReturnToStoragePool", params)
+	for _, expected := range []string{
+		`<METHODCALL NAME="ReturnToStoragePool"><LOCALINSTANCEPATH><LOCALNAMESPACEPATH><NAMESPACE NAME="root"/><NAMESPACE NAME="emc"/></LOCALNAMESPACEPATH><INSTANCENAME CLASSNAME="Symm_StorageVolume">`,
+		`<PARAMVALUE NAME="ElementName"><VALUE>vol&lt;1&gt;</VALUE></PARAMVALUE>`,
+		`<PARAMVALUE NAME="TheElement" PARAMTYPE="reference"><VALUE.REFERENCE><LOCALINSTANCEPATH>`,
+		`<PARAMVALUE NAME="Members" PARAMTYPE="reference"><VALUE.REFARRAY><VALUE.REFERENCE>`,
+		`<KEYBINDING NAME="DeviceID"><KEYVALUE VALUETYPE="string">00ABC</KEYVALUE></KEYBINDING>`,
+	} {
+		if !strings.Contains(call, expected) {
+			t.Errorf("expected %s in %s", expected, call)
+		}
+	}
+	if header := cimObjectHeader(testVolumeName); header != url.QueryEscape(`root/emc:Symm_StorageVolume.DeviceID="00ABC",SystemName="SYMMETRIX-+-000196701380"`) {
+		t.Errorf("unexpected CIMObject header %s", header)
+	}
+}
+
+func TestInvokeMethodCtx(t *testing.T) {
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("CIMMethod") != "EMCReturnToStoragePool" {
+			t.Errorf("unexpected CIMMethod %s", r.Header.Get("CIMMethod"))
+		}
+		w.Write([]byte(cimMessage(`<METHODRESPONSE NAME="EMCReturnToStoragePool">` +
+			`<RETURNVALUE PARAMTYPE="uint32"><VALUE>4096</VALUE></RETURNVALUE>` +
+			`<PARAMVALUE NAME="Job" PARAMTYPE="reference"><VALUE.REFERENCE><INSTANCEPATH><NAMESPACEPATH/>` +
+			`<INSTANCENAME CLASSNAME="SE_ConcreteJob"><KEYBINDING NAME="InstanceID"><KEYVALUE>J1</KEYVALUE></KEYBINDING></INSTANCENAME>` +
+			`</INSTANCEPATH></VALUE.REFERENCE></PARAMVALUE>` +
+			`<PARAMVALUE NAME="Size" PARAMTYPE="uint64"><VALUE>1024</VALUE></PARAMVALUE>` +
+			`</METHODRESPONSE>`)))
+	})
+	defer server.Close()
+
+	rc, params, err := client.InvokeMethodCtx(context.Background(), testVolumeName, "EMCReturnToStoragePool", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc != ReturnJobStarted || len(params) != 2 {
+		t.Fatalf("unexpected result %d %+v", rc, params)
+	}
+	if idx, err := FindJobIndex(params); err != nil || params[idx].ValueReference.InstancePath.InstanceName.ClassName != "SE_ConcreteJob" {
+		t.Errorf("job reference not decoded: %v", err)
+	}
+	if params[1].Value == nil || params[1].Value.Value != "1024" {
+		t.Errorf("Size not decoded: %+v", params[1])
+	}
+}
+
+func TestAssociatorInstancesCtx(t *testing.T) {
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if !strings.Contains(string(body), `<IPARAMVALUE NAME="ResultClass"><CLASSNAME NAME="CIM_StorageVolume"/></IPARAMVALUE>`) ||
+			strings.Contains(string(body), "AssocClass") {
+			t.Errorf("unexpected request %s", body)
+		}
+		w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="Associators"><IRETURNVALUE><VALUE.OBJECTWITHPATH>` +
+			`<INSTANCEPATH><NAMESPACEPATH/><INSTANCENAME CLASSNAME="Symm_StorageVolume"><KEYBINDING NAME="DeviceID"><KEYVALUE>00ABC</KEYVALUE></KEYBINDING></INSTANCENAME></INSTANCEPATH>` +
+			`<INSTANCE CLASSNAME="Symm_StorageVolume"><PROPERTY NAME="ElementName" TYPE="string"><VALUE>vol1</VALUE></PROPERTY></INSTANCE>` +
+			`</VALUE.OBJECTWITHPATH></IRETURNVALUE></IMETHODRESPONSE>`)))
+	})
+	defer server.Close()
+
+	objects, err := client.AssociatorInstancesCtx(context.Background(), testVolumeName, "", "CIM_StorageVolume", nil, nil, false, []string{"ElementName"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objects) != 1 || propertyString(objects[0].Instance, "ElementName") != "vol1" || objects[0].InstancePath.InstanceName.ClassName != "Symm_StorageVolume" {
+		t.Errorf("unexpected objects %+v", objects)
+	}
+}
+
+func TestCtxCancelClosesRequest(t *testing.T) {
+	aborted := make(chan struct{})
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := client.GetInstanceCtx(ctx, testVolumeName, false, nil); err == nil {
+		t.Fatal("expected the call to be cancelled")
+	}
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Error("request still open on the provider after cancel")
+	}
+}
+
+func TestXMLInstanceTypes(t *testing.T) {
+	instance, err := xmlInstance("Symm_StorageVolume", map[string]interface{}{
+		"ElementName": "db01",
+		"EMCIsBound":  true,
+		"Usage":       uint16(2),
+		"Offset":      int32(-1),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, property := range []string{
+		`<PROPERTY NAME="ElementName" TYPE="string"><VALUE>db01</VALUE></PROPERTY>`,
+		`<PROPERTY NAME="EMCIsBound" TYPE="boolean"><VALUE>true</VALUE></PROPERTY>`,
+		`<PROPERTY NAME="Usage" TYPE="uint16"><VALUE>2</VALUE></PROPERTY>`,
+		`<PROPERTY NAME="Offset" TYPE="sint32"><VALUE>-1</VALUE></PROPERTY>`,
+	} {
+		if !strings.Contains(instance, property) {
+			t.Errorf("missing %s in %s", property, instance)
+		}
+	}
+
+	if _, err := xmlInstance("Symm_StorageVolume", map[string]interface{}{"Size": 1}); err == nil {
+		t.Error("expected an error for a property without a CIM type")
+	}
+}
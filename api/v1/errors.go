@@ -0,0 +1,235 @@
+package apiv1
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+)
+
+// Sentinel errors, usable with errors.Is.  ErrNotFound is wrapped by every
+// lookup that comes back empty; the others classify *MethodError, *JobError
+// and *CIMError failures.
+var (
+	ErrNotFound      = errors.New("not found")
+	ErrAlreadyExists = errors.New("already exists")
+	ErrInUse         = errors.New("in use")
+	ErrTransient     = errors.New("transient failure")
+)
+
+// ErrJobNotDone is returned by Job.Result while the job is still running.
+var ErrJobNotDone = errors.New("job has not finished")
+
+///////////////////////////////////////////////////////////////
+//        Return values shared by the SMI-S services         //
+///////////////////////////////////////////////////////////////
+
+const (
+	ReturnCompleted         = 0
+	ReturnNotSupported      = 1
+	ReturnUnknown           = 2
+	ReturnTimeout           = 3
+	ReturnFailed            = 4
+	ReturnInvalidParameter  = 5
+	ReturnInUse             = 6
+	ReturnJobStarted        = 4096
+	ReturnSizeNotSupported  = 4097
+	ReturnVendorSpecificMin = 32768
+)
+
+var returnCodeMap = map[int]string{
+	ReturnCompleted:        "Completed with No Error",
+	ReturnNotSupported:     "Not Supported",
+	ReturnUnknown:          "Unknown",
+	ReturnTimeout:          "Timeout",
+	ReturnFailed:           "Failed",
+	ReturnInvalidParameter: "Invalid Parameter",
+	ReturnInUse:            "In Use",
+	ReturnJobStarted:       "Method Parameters Checked - Job Started",
+	ReturnSizeNotSupported: "Size Not Supported",
+}
+
+func ReturnCodeMeaning(rc int) string {
+	if meaning, ok := returnCodeMap[rc]; ok {
+		return meaning
+	}
+	switch {
+	case rc < 0:
+		return "Invalid"
+	case rc < ReturnJobStarted:
+		return "DMTF Reserved"
+	case rc < ReturnVendorSpecificMin:
+		return "Method Reserved"
+	}
+	return "Vendor Specific"
+}
+
+///////////////////////////////////////////////////////////////
+//    Error returned with a list some items were left out of //
+///////////////////////////////////////////////////////////////
+
+// PartialError is returned together with the items a List call could
+// decode; Errors holds the reason for each item left out.
+type PartialError struct {
+	Op     string
+	Errors []error
+}
+
+func (e *PartialError) Error() string {
+	msg := e.Op + ": " + strconv.Itoa(len(e.Errors)) + " item(s) skipped"
+	if len(e.Errors) > 0 {
+		msg += ", first: " + e.Errors[0].Error()
+	}
+	return msg
+}
+
+// partialError returns nil when nothing failed, so it can be returned
+// directly alongside the results.
+func partialError(op string, errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return &PartialError{Op: op, Errors: errs}
+}
+
+///////////////////////////////////////////////////////////////
+//        Error returned when an extrinsic method fails      //
+///////////////////////////////////////////////////////////////
+
+type MethodError struct {
+	Method     string
+	ReturnCode int
+	Meaning    string
+}
+
+// methodReturnCodes holds the return values a method defines on top of the
+// shared ones, with the sentinel error each is classified as.  Values are
+// from the method qualifiers in the CIM schema.
+var methodReturnCodes = map[string]map[int]methodReturn{
+	"CreateStorageHardwareID": {
+		4096: {"ID already created", ErrAlreadyExists},
+		4097: {"Hardware implementation does not support specified IDType", nil},
+	},
+}
+
+type methodReturn struct {
+	meaning string
+	err     error
+}
+
+func NewMethodError(method string, rc int) *MethodError {
+	meaning := ReturnCodeMeaning(rc)
+	if ret, ok := methodReturnCodes[method][rc]; ok {
+		meaning = ret.meaning
+	}
+	return &MethodError{
+		Method:     method,
+		ReturnCode: rc,
+		Meaning:    meaning,
+	}
+}
+
+func (e *MethodError) Error() string {
+	return e.Method + " failed, rc = " + strconv.Itoa(e.ReturnCode) + " (" + e.Meaning + ")"
+}
+
+func (e *MethodError) Is(target error) bool {
+	if ret, ok := methodReturnCodes[e.Method][e.ReturnCode]; ok {
+		return ret.err != nil && ret.err == target
+	}
+	switch target {
+	case ErrInUse:
+		return e.ReturnCode == ReturnInUse
+	case ErrTransient:
+		return e.ReturnCode == ReturnTimeout
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+//      Error returned when an array job does not complete   //
+///////////////////////////////////////////////////////////////
+
+type JobError struct {
+	JobPath          *gowbem.InstancePath
+	State            string
+	ErrorCode        int
+	ErrorDescription string
+}
+
+func newJobError(jobPath *gowbem.InstancePath, job *gowbem.Instance, state string) *JobError {
+	jobErr := &JobError{
+		JobPath: jobPath,
+		State:   state,
+	}
+	if job != nil {
+		jobErr.ErrorCode, _ = strconv.Atoi(propertyString(job, "ErrorCode"))
+		jobErr.ErrorDescription = propertyString(job, "ErrorDescription")
+	}
+	return jobErr
+}
+
+func (e *JobError) Error() string {
+	msg := "Job " + e.State
+	if e.ErrorCode != 0 {
+		msg += ", error code = " + strconv.Itoa(e.ErrorCode)
+	}
+	if e.ErrorDescription != "" {
+		msg += ": " + e.ErrorDescription
+	}
+	return msg
+}
+
+// jobErrorPhrases are matched as whole words against a failed job's
+// ErrorDescription, which is the only place SE_ConcreteJob reports why it
+// failed.
+var jobErrorPhrases = map[error][]string{
+	ErrAlreadyExists: {"already exists", "already exist"},
+	ErrInUse:         {"in use", "is a member of"},
+	ErrTransient:     {"locked", "lock held", "busy", "timed out", "timeout"},
+	ErrNotFound:      {"not found", "does not exist"},
+}
+
+// Is classifies the job failure from the provider's ErrorDescription.
+func (e *JobError) Is(target error) bool {
+	desc := strings.ToLower(e.ErrorDescription)
+	for _, phrase := range jobErrorPhrases[target] {
+		if containsPhrase(desc, phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsPhrase reports whether phrase occurs in s with no letter or digit
+// on either side, so "locked" does not match "unlocked" or "blocked".
+func containsPhrase(s, phrase string) bool {
+	for i := 0; ; {
+		n := strings.Index(s[i:], phrase)
+		if n < 0 {
+			return false
+		}
+		start, end := i+n, i+n+len(phrase)
+		if (start == 0 || !isWordByte(s[start-1])) && (end == len(s) || !isWordByte(s[end])) {
+			return true
+		}
+		i = start + 1
+	}
+}
+
+func isWordByte(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z' || b >= '0' && b <= '9' || b == '_'
+}
+
+func (e *CIMError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.Code == CIMErrNotFound
+	case ErrAlreadyExists:
+		return e.Code == CIMErrAlreadyExists
+	case ErrTransient:
+		return e.Code == CIMErrServerLimitsExceeded || e.Code == CIMErrServerIsShuttingDown
+	}
+	return false
+}
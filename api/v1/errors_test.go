@@ -0,0 +1,106 @@
+package apiv1
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestGetVolumeByIDNotFound(t *testing.T) {
+	_, err := smis.GetVolumeByID(testingInstance, "govmax_no_such_volume")
+	if !errors.Is(err, ErrNotFound) {
+		t.Log("expected ErrNotFound, got", err)
+		t.Fail()
+	}
+}
+
+func TestMethodError(t *testing.T) {
+	var err error = NewMethodError("DeleteGroup", ReturnInUse)
+
+	var methodErr *MethodError
+	if !errors.As(err, &methodErr) || methodErr.Meaning != "In Use" {
+		t.Log("unexpected error", err)
+		t.Fail()
+	}
+	if !errors.Is(err, ErrInUse) {
+		t.Log("expected ErrInUse")
+		t.Fail()
+	}
+	if errors.Is(err, ErrTransient) {
+		t.Log("unexpected ErrTransient")
+		t.Fail()
+	}
+}
+
+func TestPostCreateGroupDuplicate(t *testing.T) {
+	group, err := smis.PostCreateGroup(testingInstance, "govmax_test_dup_sg", 4)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, group, false)
+
+	_, err = smis.PostCreateGroup(testingInstance, "govmax_test_dup_sg", 4)
+	var methodErr *MethodError
+	if !errors.As(err, &methodErr) {
+		t.Log("expected *MethodError, got", err)
+		t.Fail()
+		return
+	}
+	t.Log(methodErr.Error())
+}
+
+func TestPartialError(t *testing.T) {
+	if err := partialError("ListVolumeDetails", nil); err != nil {
+		t.Log("expected nil for no failures, got", err)
+		t.Fail()
+	}
+	err := partialError("ListVolumeDetails", []error{errors.New("bad volume")})
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(partial.Errors) != 1 {
+		t.Log("unexpected error", err)
+		t.Fail()
+	}
+}
+
+func TestMethodErrorAlreadyExists(t *testing.T) {
+	err := NewMethodError("CreateStorageHardwareID", 4096)
+	if !errors.Is(err, ErrAlreadyExists) || err.Meaning != "ID already created" {
+		t.Log("expected ErrAlreadyExists, got", err)
+		t.Fail()
+	}
+	if errors.Is(NewMethodError("CreateGroup", 4096), ErrAlreadyExists) {
+		t.Log("4096 is only already exists for CreateStorageHardwareID")
+		t.Fail()
+	}
+}
+
+func TestJobErrorIs(t *testing.T) {
+	tests := []struct {
+		desc   string
+		target error
+		want   bool
+	}{
+		{"Storage group already exists", ErrAlreadyExists, true},
+		{"The device is in use", ErrInUse, true},
+		{"Volume is a member of another group", ErrInUse, true},
+		{"Device is locked by another session", ErrTransient, true},
+		{"Array busy, retry", ErrTransient, true},
+		{"Request timed out", ErrTransient, true},
+		{"Initiator not found", ErrNotFound, true},
+		{"Group does not exist", ErrNotFound, true},
+		{"Device is blocked", ErrTransient, false},
+		{"Device unlocked", ErrTransient, false},
+		{"Clock skew detected", ErrTransient, false},
+		{"Masking view could not be created", ErrInUse, false},
+		{"Storage group is not in use by a masking view", ErrNotFound, false},
+		{"Reused port", ErrInUse, false},
+		{"", ErrNotFound, false},
+	}
+	for _, test := range tests {
+		err := &JobError{State: "Exception", ErrorDescription: test.desc}
+		if got := errors.Is(err, test.target); got != test.want {
+			t.Errorf("%q: errors.Is(%v) = %v, want %v", test.desc, test.target, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,543 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+////////////////////////////////////////////////////////////////
+//   One masking view through which a volume is visible and   //
+//      the initiators, front-end ports and HLU it uses       //
+////////////////////////////////////////////////////////////////
+
+type VolumeExport struct {
+	MaskingView     *gowbem.InstanceName
+	MaskingViewName string
+	HLU             int
+	Initiators      []string
+	Ports           []string
+}
+
+// findVolumeCtx looks a volume up by DeviceID, falling back to its WWN.
+func (smis *SMIS) findVolumeCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume string) (*gowbem.InstanceName, error) {
+	name, err := smis.GetVolumeByIDCtx(ctx, systemInstance, volume)
+	if err == nil || !errors.Is(err, ErrNotFound) {
+		return name, err
+	}
+
+	idx, err := smis.IndexVolumesCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	if vol, ok := idx.ByWWN(volume); ok {
+		return vol.InstanceName, nil
+	}
+	return nil, fmt.Errorf("Volume %s %w", volume, ErrNotFound)
+}
+
+// memberPropertiesCtx returns a property of every member of a group and of
+// its child groups.
+func (smis *SMIS) memberPropertiesCtx(ctx context.Context, group *gowbem.InstanceName, memberClass, property string) ([]string, error) {
+	collection, member := "Collection", "Member"
+	members, err := smis.AssociatorInstancesCtx(ctx, group, memberOfCollection, memberClass, &collection, &member, false, []string{property})
+	if err != nil {
+		return nil, err
+	}
+
+	var values []string
+	for _, m := range members {
+		if value := propertyString(m.Instance, property); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	children, err := smis.groupMembersCtx(ctx, group, group.ClassName)
+	if err != nil {
+		return nil, err
+	}
+	for _, child := range children {
+		childValues, err := smis.memberPropertiesCtx(ctx, child.InstanceName, memberClass, property)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, childValues...)
+	}
+	return values, nil
+}
+
+///////////////////////////////////////////////////////////////
+//     GET every masking view exporting a Storage Volume     //
+//           volume is a DeviceID or a WWN                   //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetVolumeExports(systemInstance *gowbem.InstanceName, volume string) ([]VolumeExport, error) {
+	return smis.GetVolumeExportsCtx(context.Background(), systemInstance, volume)
+}
+
+func (smis *SMIS) GetVolumeExportsCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume string) ([]VolumeExport, error) {
+	volumeName, err := smis.findVolumeCtx(ctx, systemInstance, volume)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each CIM_ProtocolControllerForUnit links the volume to one masking
+	// view and carries the HLU it is seen at.
+	units, err := smis.ReferencesCtx(ctx, volumeName, "CIM_ProtocolControllerForUnit", "Dependent")
+	if err != nil {
+		return nil, err
+	}
+
+	var exports []VolumeExport
+	for _, unit := range units {
+		view, err := unit.Reference("Antecedent")
+		if err != nil || view.ClassName != MaskingViewClass {
+			continue
+		}
+		hlu, err := ParseHLU(unit.Property("DeviceNumber"))
+		if err != nil {
+			return nil, err
+		}
+		export := VolumeExport{
+			MaskingView: view,
+			HLU:         hlu,
+		}
+
+		instance, err := smis.GetInstanceCtx(ctx, view, false, []string{"ElementName"})
+		if err != nil {
+			return nil, err
+		}
+		export.MaskingViewName = propertyString(instance, "ElementName")
+
+		igs, err := smis.AssociatorNamesCtx(ctx, view, "", InitiatorGroupClass, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, ig := range igs {
+			initiators, err := smis.memberPropertiesCtx(ctx, ig.InstancePath.InstanceName, "SE_StorageHardwareID", "StorageID")
+			if err != nil {
+				return nil, err
+			}
+			export.Initiators = append(export.Initiators, initiators...)
+		}
+
+		pgs, err := smis.AssociatorNamesCtx(ctx, view, "", PortGroupClass, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, pg := range pgs {
+			ports, err := smis.memberPropertiesCtx(ctx, pg.InstancePath.InstanceName, "CIM_SCSIProtocolEndpoint", "Name")
+			if err != nil {
+				return nil, err
+			}
+			export.Ports = append(export.Ports, ports...)
+		}
+
+		exports = append(exports, export)
+	}
+	return exports, nil
+}
+
+////////////////////////////////////////////////////////////////
+//      Host a volume is exported to by ExportVolumeToHost    //
+//                                                            //
+//   Group and view names default to HostName with an _SG,    //
+//   _IG or _MV suffix.  PortGroup must already exist.        //
+//   JournalPath, when set, is where the steps taken are      //
+//   saved until the export completes; see OpenJournal.       //
+////////////////////////////////////////////////////////////////
+
+type HostExport struct {
+	HostName           string
+	Initiators         []string
+	PortGroup          *gowbem.InstancePath
+	StorageGroupName   string
+	InitiatorGroupName string
+	MaskingViewName    string
+	JournalPath        string
+}
+
+func (h *HostExport) withDefaults() HostExport {
+	export := *h
+	if export.StorageGroupName == "" {
+		export.StorageGroupName = h.HostName + "_SG"
+	}
+	if export.InitiatorGroupName == "" {
+		export.InitiatorGroupName = h.HostName + "_IG"
+	}
+	if export.MaskingViewName == "" {
+		export.MaskingViewName = h.HostName + "_MV"
+	}
+	return export
+}
+
+// HardwareIDTypeOf guesses the type of an initiator ID: iSCSI names start
+// with iqn. or eui., anything else is taken as a WWN.
+func HardwareIDTypeOf(storageID string) HardwareIDType {
+	id := strings.ToLower(storageID)
+	if strings.HasPrefix(id, "iqn.") || strings.HasPrefix(id, "eui.") {
+		return HardwareIDTypeIQN
+	}
+	return HardwareIDTypeWWN
+}
+
+///////////////////////////////////////////////////////////////
+//     GET a Storage Hardware ID by its WWN or IQN           //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetStorageHardwareIDByStorageID(systemInstance *gowbem.InstanceName, storageID string) (*gowbem.InstancePath, error) {
+	return smis.GetStorageHardwareIDByStorageIDCtx(context.Background(), systemInstance, storageID)
+}
+
+func (smis *SMIS) GetStorageHardwareIDByStorageIDCtx(ctx context.Context, systemInstance *gowbem.InstanceName, storageID string) (*gowbem.InstancePath, error) {
+	service, err := smis.GetStorageHardwareIDManagementServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := smis.AssociatorInstancesCtx(ctx, service, "", "SE_StorageHardwareID", nil, nil, false, []string{"StorageID"})
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		if strings.EqualFold(propertyString(id.Instance, "StorageID"), storageID) {
+			return id.InstancePath, nil
+		}
+	}
+	return nil, fmt.Errorf("HardwareID %s %w", storageID, ErrNotFound)
+}
+
+func containsInstance(paths []gowbem.InstancePath, path *gowbem.InstancePath) bool {
+	for idx := range paths {
+		if sameInstanceName(paths[idx].InstanceName, path.InstanceName) {
+			return true
+		}
+	}
+	return false
+}
+
+///////////////////////////////////////////////////////////////
+//        EXPORT a Storage Volume to a host                  //
+//                                                           //
+//   Existing initiators, groups and views are reused, and   //
+//   missing ones created.  An existing view must use the    //
+//   requested port group and exactly the host's initiators. //
+//   When it has a cascaded storage group the volume goes    //
+//   into the child named StorageGroupName, or the only      //
+//   child.  If a step fails, everything created by this     //
+//   call is removed.                                        //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ExportVolumeToHost(systemInstance *gowbem.InstanceName, volume *gowbem.InstancePath, host *HostExport) (*gowbem.InstancePath, error) {
+	return smis.ExportVolumeToHostCtx(context.Background(), systemInstance, volume, host)
+}
+
+func (smis *SMIS) ExportVolumeToHostCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume *gowbem.InstancePath, host *HostExport) (view *gowbem.InstancePath, err error) {
+	if host.HostName == "" || len(host.Initiators) == 0 || host.PortGroup == nil {
+		return nil, errors.New("HostName, Initiators and PortGroup are required")
+	}
+	req := host.withDefaults()
+
+	journal, err := smis.NewJournal(req.JournalPath)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		err = journal.finish(err)
+	}()
+
+	// The masking view already exists: only the volume may be missing.
+	mv, err := smis.findMaskingViewCtx(ctx, systemInstance, req.MaskingViewName)
+	if err == nil {
+		if mv.StorageGroup == nil {
+			return nil, errors.New("Masking view " + req.MaskingViewName + " has no storage group")
+		}
+		if err = smis.checkExportViewCtx(ctx, mv, &req); err != nil {
+			return nil, err
+		}
+		groups, err := smis.viewStorageGroupsCtx(ctx, mv.StorageGroup)
+		if err != nil {
+			return nil, err
+		}
+		if holdingStorageGroup(groups, volume) != nil {
+			return mv.InstancePath, nil
+		}
+		target, err := exportStorageGroup(groups, req.StorageGroupName)
+		if err != nil {
+			return nil, err
+		}
+		err = journal.AddMembersToGroupCtx(ctx, systemInstance, target.InstancePath, []gowbem.InstancePath{*volume})
+		return mv.InstancePath, err
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	var initiators []gowbem.InstancePath
+	for _, storageID := range req.Initiators {
+		id, err := smis.GetStorageHardwareIDByStorageIDCtx(ctx, systemInstance, storageID)
+		if errors.Is(err, ErrNotFound) {
+			id, err = journal.CreateStorageHardwareIDCtx(ctx, systemInstance, storageID, HardwareIDTypeOf(storageID))
+		}
+		if err != nil {
+			return nil, err
+		}
+		initiators = append(initiators, *id)
+	}
+
+	ig, err := smis.ensureInitiatorGroupCtx(ctx, systemInstance, req.InitiatorGroupName, initiators, journal)
+	if err != nil {
+		return nil, err
+	}
+	sg, err := smis.ensureGroupCtx(ctx, systemInstance, StorageGroupClass, req.StorageGroupName, []gowbem.InstancePath{*volume}, journal)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := journal.CreateMaskingViewCtx(ctx, systemInstance, req.MaskingViewName, sg, ig, req.PortGroup)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range created {
+		if path.InstancePath != nil && path.InstancePath.InstanceName.ClassName == MaskingViewClass {
+			return path.InstancePath, nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s %w", MaskingViewClass, req.MaskingViewName, ErrNotFound)
+}
+
+// checkExportViewCtx makes sure an existing view is the host's: it must use
+// the requested port group, and its initiator group, children included,
+// must hold exactly the requested initiators.  Otherwise the volume would
+// be exported to another host or through other ports.
+func (smis *SMIS) checkExportViewCtx(ctx context.Context, mv *MaskingView, req *HostExport) error {
+	if mv.PortGroup == nil || !sameInstanceName(mv.PortGroup.InstancePath.InstanceName, req.PortGroup.InstanceName) {
+		return fmt.Errorf("Masking view %s does not use the requested port group: %w", mv.Name, ErrAlreadyExists)
+	}
+	if mv.InitiatorGroup == nil {
+		return errors.New("Masking view " + mv.Name + " has no initiator group")
+	}
+	storageIDs, err := smis.memberPropertiesCtx(ctx, mv.InitiatorGroup.InstancePath.InstanceName, "SE_StorageHardwareID", "StorageID")
+	if err != nil {
+		return err
+	}
+	inGroup := make(map[string]bool)
+	for _, storageID := range storageIDs {
+		inGroup[strings.ToLower(storageID)] = true
+	}
+	requested := make(map[string]bool)
+	for _, storageID := range req.Initiators {
+		requested[strings.ToLower(storageID)] = true
+	}
+	for storageID := range requested {
+		if !inGroup[storageID] {
+			return fmt.Errorf("Masking view %s: initiator %s is not in initiator group %s: %w", mv.Name, storageID, mv.InitiatorGroup.Name, ErrAlreadyExists)
+		}
+	}
+	for storageID := range inGroup {
+		if !requested[storageID] {
+			return fmt.Errorf("Masking view %s: initiator group %s also holds initiator %s: %w", mv.Name, mv.InitiatorGroup.Name, storageID, ErrAlreadyExists)
+		}
+	}
+	return nil
+}
+
+// ensureGroupCtx finds or creates a named group and adds the members it is
+// missing, recording each change in the journal.
+func (smis *SMIS) ensureGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, className, name string, members []gowbem.InstancePath, journal *Journal) (*gowbem.InstancePath, error) {
+	var current []gowbem.InstancePath
+	group, err := smis.findGroupByNameCtx(ctx, systemInstance, className, name)
+	switch {
+	case err == nil:
+		path := group.InstancePath
+		if current, err = smis.associatedPathsCtx(ctx, path.InstanceName, memberOfCollection, "", "Collection", "Member"); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, ErrNotFound):
+		groupType := GroupTypeStorage
+		if className == InitiatorGroupClass {
+			groupType = GroupTypeInitiator
+		}
+		path, err := journal.CreateGroupCtx(ctx, systemInstance, name, groupType)
+		if err != nil {
+			return nil, err
+		}
+		group = &gowbem.ValueObjectWithPath{InstancePath: path}
+	default:
+		return nil, err
+	}
+
+	return group.InstancePath, addMissingMembersCtx(ctx, systemInstance, group.InstancePath, current, members, journal)
+}
+
+// addMissingMembersCtx adds the members that are not already in current.
+func addMissingMembersCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, current, members []gowbem.InstancePath, journal *Journal) error {
+	var missing []gowbem.InstancePath
+	for idx := range members {
+		if !containsInstance(current, &members[idx]) {
+			missing = append(missing, members[idx])
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+	return journal.AddMembersToGroupCtx(ctx, systemInstance, group, missing)
+}
+
+// ensureInitiatorGroupCtx reuses the initiator group the initiators are
+// already in, whatever its name, since an initiator can only be in one
+// group.  Only when none of them is in a group is the named one used.
+func (smis *SMIS) ensureInitiatorGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, name string, initiators []gowbem.InstancePath, journal *Journal) (*gowbem.InstancePath, error) {
+	var group *gowbem.InstancePath
+	for idx := range initiators {
+		groups, err := smis.associatedPathsCtx(ctx, initiators[idx].InstanceName, memberOfCollection, InitiatorGroupClass, "Member", "Collection")
+		if err != nil {
+			return nil, err
+		}
+		for g := range groups {
+			if group != nil && !sameInstanceName(group.InstanceName, groups[g].InstanceName) {
+				return nil, fmt.Errorf("Initiators are in more than one initiator group: %w", ErrInUse)
+			}
+			group = &groups[g]
+		}
+	}
+	if group == nil {
+		return smis.ensureGroupCtx(ctx, systemInstance, InitiatorGroupClass, name, initiators, journal)
+	}
+
+	current, err := smis.groupMembersCtx(ctx, group.InstanceName, "SE_StorageHardwareID")
+	if err != nil {
+		return nil, err
+	}
+	return group, addMissingMembersCtx(ctx, systemInstance, group, current, initiators, journal)
+}
+
+// viewStorageGroupsCtx returns the storage group of a masking view, or the
+// children of a cascaded one, which are the groups that hold its volumes.
+func (smis *SMIS) viewStorageGroupsCtx(ctx context.Context, sg *StorageGroup) ([]*StorageGroup, error) {
+	if len(sg.Children) == 0 {
+		return []*StorageGroup{sg}, nil
+	}
+	var children []*StorageGroup
+	for idx := range sg.Children {
+		instance, err := smis.GetInstanceCtx(ctx, sg.Children[idx].InstanceName, false, groupProperties)
+		if err != nil {
+			return nil, err
+		}
+		child, err := smis.resolveStorageGroupCtx(ctx, &gowbem.ValueObjectWithPath{InstancePath: &sg.Children[idx], Instance: instance})
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	return children, nil
+}
+
+// holdingStorageGroup returns the group that holds the volume, or nil.
+func holdingStorageGroup(groups []*StorageGroup, volume *gowbem.InstancePath) *StorageGroup {
+	for _, sg := range groups {
+		if containsInstance(sg.Volumes, volume) {
+			return sg
+		}
+	}
+	return nil
+}
+
+// exportStorageGroup picks the group a volume is added to: the only one, or
+// the child of a cascaded group named name.
+func exportStorageGroup(groups []*StorageGroup, name string) (*StorageGroup, error) {
+	if len(groups) == 1 {
+		return groups[0], nil
+	}
+	for _, sg := range groups {
+		if sg.Name == name {
+			return sg, nil
+		}
+	}
+	return nil, fmt.Errorf("Cascaded storage group has no child %s; set StorageGroupName to one of its children: %w", name, ErrNotFound)
+}
+
+///////////////////////////////////////////////////////////////
+//        UNEXPORT a Storage Volume from a host              //
+//                                                           //
+//   The volume is removed from the host's storage group,    //
+//   or the child holding it when the group is cascaded.     //
+//   When that leaves the view empty the masking view and    //
+//   storage group are deleted, and the initiator group too  //
+//   unless another view uses it.  The view and volume are   //
+//   restored if either step fails.  Unexporting a volume    //
+//   that is not exported does nothing.                      //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) UnexportVolumeFromHost(systemInstance *gowbem.InstanceName, volume *gowbem.InstancePath, host *HostExport) error {
+	return smis.UnexportVolumeFromHostCtx(context.Background(), systemInstance, volume, host)
+}
+
+func (smis *SMIS) UnexportVolumeFromHostCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume *gowbem.InstancePath, host *HostExport) error {
+	req := host.withDefaults()
+
+	mv, err := smis.findMaskingViewCtx(ctx, systemInstance, req.MaskingViewName)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	sg := mv.StorageGroup
+	if sg == nil {
+		return nil
+	}
+	groups, err := smis.viewStorageGroupsCtx(ctx, sg)
+	if err != nil {
+		return err
+	}
+	holding := holdingStorageGroup(groups, volume)
+	if holding == nil {
+		return nil
+	}
+	viewVolumes := 0
+	for _, group := range groups {
+		viewVolumes += len(group.Volumes)
+	}
+
+	journal, err := smis.NewJournal(req.JournalPath)
+	if err != nil {
+		return err
+	}
+
+	// The last volume cannot leave a storage group that is in a view.
+	if viewVolumes > 1 {
+		err = journal.RemoveMembersFromGroupCtx(ctx, systemInstance, holding.InstancePath, []gowbem.InstancePath{*volume})
+		return journal.finish(err)
+	}
+
+	// Deleting the view and taking the volume out are undone together if
+	// either fails, so the host never loses the view while keeping the
+	// volume, or the reverse.
+	if err = journal.DeleteMaskingViewCtx(ctx, systemInstance, mv); err == nil {
+		err = journal.RemoveMembersFromGroupCtx(ctx, systemInstance, holding.InstancePath, []gowbem.InstancePath{*volume})
+	}
+	if err = journal.finish(err); err != nil {
+		return err
+	}
+
+	// The volume is unexported; what follows only removes groups left
+	// empty, and a failure leaves them in place.
+	if len(sg.MaskingViews) <= 1 && len(sg.Parents) == 0 {
+		if err = smis.PostDeleteGroupCtx(ctx, systemInstance, sg.InstancePath, true); err != nil {
+			return err
+		}
+		// A child is left without its parent and empty.
+		if holding != sg && len(holding.MaskingViews) == 0 {
+			if err = smis.PostDeleteGroupCtx(ctx, systemInstance, holding.InstancePath, true); err != nil {
+				return err
+			}
+		}
+	}
+	if ig := mv.InitiatorGroup; ig != nil && len(ig.MaskingViews) <= 1 && len(ig.Parents) == 0 {
+		return smis.PostDeleteGroupCtx(ctx, systemInstance, ig.InstancePath, true)
+	}
+	return nil
+}
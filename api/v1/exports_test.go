@@ -0,0 +1,251 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+func TestGetVolumeExports(t *testing.T) {
+	views, err := smis.ListMaskingViewDetails(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	for _, mv := range views {
+		if len(mv.Luns) == 0 {
+			continue
+		}
+		lun := mv.Luns[0]
+		exports, err := smis.GetVolumeExports(testingInstance, lun.DeviceID)
+		if err != nil {
+			t.Log(err.Error())
+			t.Fail()
+			return
+		}
+
+		found := false
+		for _, export := range exports {
+			fmt.Printf("%s view=%s hlu=%d initiators=%v ports=%v\n", lun.DeviceID, export.MaskingViewName, export.HLU, export.Initiators, export.Ports)
+			if export.MaskingViewName == mv.Name && export.HLU == lun.HLU {
+				found = true
+			}
+		}
+		if !found {
+			t.Log("masking view " + mv.Name + " not in exports")
+			t.Fail()
+		}
+		return
+	}
+}
+
+func TestGetVolumeExportsNotFound(t *testing.T) {
+	_, err := smis.GetVolumeExports(testingInstance, "no_such_volume")
+	if !errors.Is(err, ErrNotFound) {
+		t.Log("expected ErrNotFound, got", err)
+		t.Fail()
+	}
+}
+
+func TestExportVolumeToHost(t *testing.T) {
+	pools, _ := smis.GetStoragePools(testingInstance)
+	volumes, err := smis.PostVolumes(&PostVolumesReq{
+		ElementName:        "govmax_test_export",
+		ElementType:        "2",
+		EMCNumberOfDevices: "1",
+		Size:               "123",
+		InPool:             pools[0].InstancePath.InstanceName,
+	}, testingInstance)
+	if err != nil || len(volumes) == 0 {
+		t.Log("failed to create volume", err)
+		t.Fail()
+		return
+	}
+	volPath := volumes[0].InstancePath
+	defer smis.PostDeleteVol(testingInstance, []gowbem.InstancePath{*volPath})
+
+	pg, err := smis.CreateGroup(testingInstance, "govmax_test_export_PG", GroupTypePort)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, pg, true)
+	ports, _ := smis.GetTargetEndpoints(testingInstance)
+	if len(ports) > 0 {
+		smis.AddMembersToGroup(testingInstance, pg, []gowbem.InstancePath{*ports[0].InstancePath})
+	}
+
+	host := &HostExport{
+		HostName:   "govmax_test_export",
+		Initiators: []string{"10000000C94E5D22"},
+		PortGroup:  pg,
+	}
+	view, err := smis.ExportVolumeToHost(testingInstance, volPath, host)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	DumpInstanceClass(view.InstanceName)
+
+	// a second export of the same volume reuses everything
+	if _, err = smis.ExportVolumeToHost(testingInstance, volPath, host); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if err = smis.UnexportVolumeFromHost(testingInstance, volPath, host); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+	if _, err = smis.GetMaskingViewByName(testingInstance, "govmax_test_export_MV"); !errors.Is(err, ErrNotFound) {
+		t.Log("masking view not removed", err)
+		t.Fail()
+	}
+}
+
+func TestExportVolumeToHostExistingIG(t *testing.T) {
+	pools, _ := smis.GetStoragePools(testingInstance)
+	volumes, err := smis.PostVolumes(&PostVolumesReq{
+		ElementName:        "govmax_test_export_ig",
+		ElementType:        "2",
+		EMCNumberOfDevices: "1",
+		Size:               "123",
+		InPool:             pools[0].InstancePath.InstanceName,
+	}, testingInstance)
+	if err != nil || len(volumes) == 0 {
+		t.Log("failed to create volume", err)
+		t.Fail()
+		return
+	}
+	volPath := volumes[0].InstancePath
+	defer smis.PostDeleteVol(testingInstance, []gowbem.InstancePath{*volPath})
+
+	pg, err := smis.CreateGroup(testingInstance, "govmax_test_export_ig_PG", GroupTypePort)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, pg, true)
+	ports, _ := smis.GetTargetEndpoints(testingInstance)
+	if len(ports) > 0 {
+		smis.AddMembersToGroup(testingInstance, pg, []gowbem.InstancePath{*ports[0].InstancePath})
+	}
+
+	// the initiator is already in a group that does not follow the naming
+	id, err := smis.CreateStorageHardwareID(testingInstance, "10000000C94E5D23", HardwareIDTypeWWN)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.DeleteStorageHardwareID(testingInstance, id)
+	ig, err := smis.CreateGroup(testingInstance, "govmax_test_legacy_ig", GroupTypeInitiator)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, ig, true)
+	if err = smis.AddMembersToGroup(testingInstance, ig, []gowbem.InstancePath{*id}); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	host := &HostExport{
+		HostName:   "govmax_test_export_ig",
+		Initiators: []string{"10000000C94E5D23"},
+		PortGroup:  pg,
+	}
+	if _, err = smis.ExportVolumeToHost(testingInstance, volPath, host); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.UnexportVolumeFromHost(testingInstance, volPath, host)
+
+	mv, err := smis.GetMaskingViewByName(testingInstance, "govmax_test_export_ig_MV")
+	if err != nil || mv.InitiatorGroup == nil || mv.InitiatorGroup.Name != "govmax_test_legacy_ig" {
+		t.Log("existing initiator group not reused", err)
+		t.Fail()
+	}
+}
+
+func TestCascadedExportStorageGroup(t *testing.T) {
+	volume := &gowbem.InstancePath{InstanceName: testVolumeName}
+	data := &StorageGroup{Name: "app_data_sg"}
+	logs := &StorageGroup{Name: "app_log_sg", Volumes: []gowbem.InstancePath{*volume}}
+	children := []*StorageGroup{data, logs}
+
+	if holding := holdingStorageGroup(children, volume); holding != logs {
+		t.Log("volume not found in its child group")
+		t.Fail()
+	}
+	if target, err := exportStorageGroup(children, "app_data_sg"); err != nil || target != data {
+		t.Log("volume not added to the named child", err)
+		t.Fail()
+	}
+	if _, err := exportStorageGroup(children, "app_sg"); !errors.Is(err, ErrNotFound) {
+		t.Log("expected ErrNotFound without a matching child, got", err)
+		t.Fail()
+	}
+	if target, err := exportStorageGroup(children[:1], "app_sg"); err != nil || target != data {
+		t.Log("only child not used", err)
+		t.Fail()
+	}
+}
+
+func TestCheckExportView(t *testing.T) {
+	// The view's initiator group holds one initiator and no child groups.
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		method := r.Header.Get("CIMMethod")
+		if method == "Associators" {
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="Associators"><IRETURNVALUE><VALUE.OBJECTWITHPATH>` +
+				`<INSTANCEPATH><NAMESPACEPATH/><INSTANCENAME CLASSNAME="SE_StorageHardwareID"><KEYBINDING NAME="InstanceID"><KEYVALUE>W-+-10000000C94E5D22</KEYVALUE></KEYBINDING></INSTANCENAME></INSTANCEPATH>` +
+				`<INSTANCE CLASSNAME="SE_StorageHardwareID"><PROPERTY NAME="StorageID" TYPE="string"><VALUE>10000000C94E5D22</VALUE></PROPERTY></INSTANCE>` +
+				`</VALUE.OBJECTWITHPATH></IRETURNVALUE></IMETHODRESPONSE>`)))
+			return
+		}
+		w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="` + method + `"><IRETURNVALUE></IRETURNVALUE></IMETHODRESPONSE>`)))
+	})
+	defer server.Close()
+
+	pg := &gowbem.InstancePath{InstanceName: testGroupName(PortGroupClass, "SYMMETRIX-+-000196701380+host01_PG")}
+	otherPG := &gowbem.InstancePath{InstanceName: testGroupName(PortGroupClass, "SYMMETRIX-+-000196701380+other_PG")}
+	ig := &gowbem.InstancePath{InstanceName: testGroupName(InitiatorGroupClass, "SYMMETRIX-+-000196701380+host01_IG")}
+	mv := &MaskingView{
+		Name:           "host01_MV",
+		InitiatorGroup: &InitiatorGroup{InstancePath: ig, Name: "host01_IG"},
+		PortGroup:      &PortGroup{InstancePath: pg},
+	}
+
+	tests := []struct {
+		initiators []string
+		portGroup  *gowbem.InstancePath
+		ok         bool
+	}{
+		{[]string{"10000000c94e5d22"}, pg, true},
+		{[]string{"10000000C94E5D22", "10000000C94E5D23"}, pg, false},
+		{[]string{"10000000C94E5D23"}, pg, false},
+		{[]string{"10000000C94E5D22"}, otherPG, false},
+	}
+	for _, test := range tests {
+		req := &HostExport{HostName: "host01", Initiators: test.initiators, PortGroup: test.portGroup}
+		err := client.checkExportViewCtx(context.Background(), mv, req)
+		if test.ok && err != nil {
+			t.Errorf("%v: unexpected error %v", test.initiators, err)
+		}
+		if !test.ok && !errors.Is(err, ErrAlreadyExists) {
+			t.Errorf("%v: expected ErrAlreadyExists, got %v", test.initiators, err)
+		}
+	}
+}
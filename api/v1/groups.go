@@ -0,0 +1,561 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+// Class names of the three kinds of masking group and of the masking view
+// that ties them together.
+const (
+	StorageGroupClass   = "SE_DeviceMaskingGroup"
+	PortGroupClass      = "SE_TargetMaskingGroup"
+	InitiatorGroupClass = "SE_InitiatorMaskingGroup"
+	MaskingViewClass    = "Symm_LunMaskingView"
+)
+
+// Group members, and child groups of a cascaded group, are linked to the
+// group by CIM_OrderedMemberOfCollection.
+const memberOfCollection = "CIM_OrderedMemberOfCollection"
+
+var groupProperties = []string{"ElementName", "InstanceID"}
+
+////////////////////////////////////////////////////////////////
+//         Structs used to store decoded Masking Groups       //
+//                                                            //
+//   Parents and Children are only set for cascaded groups.   //
+////////////////////////////////////////////////////////////////
+
+type StorageGroup struct {
+	InstancePath *gowbem.InstancePath
+	Name         string
+	InstanceID   string
+	Volumes      []gowbem.InstancePath
+	Parents      []gowbem.InstancePath
+	Children     []gowbem.InstancePath
+	MaskingViews []gowbem.InstancePath
+}
+
+type PortGroup struct {
+	InstancePath *gowbem.InstancePath
+	Name         string
+	InstanceID   string
+	Ports        []gowbem.InstancePath
+	MaskingViews []gowbem.InstancePath
+}
+
+type InitiatorGroup struct {
+	InstancePath *gowbem.InstancePath
+	Name         string
+	InstanceID   string
+	Initiators   []gowbem.InstancePath
+	Parents      []gowbem.InstancePath
+	Children     []gowbem.InstancePath
+	MaskingViews []gowbem.InstancePath
+}
+
+///////////////////////////////////////////////////////////////
+//      Helpers shared by the masking group lookups          //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) listGroupsCtx(ctx context.Context, systemInstance *gowbem.InstanceName, className string) ([]gowbem.ValueObjectWithPath, error) {
+	controller, err := smis.GetControllerConfigurationServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	return smis.AssociatorInstancesCtx(ctx, controller, "", className, nil, nil, false, groupProperties)
+}
+
+func (smis *SMIS) findGroupByNameCtx(ctx context.Context, systemInstance *gowbem.InstanceName, className, name string) (*gowbem.ValueObjectWithPath, error) {
+	groups, err := smis.listGroupsCtx(ctx, systemInstance, className)
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		if propertyString(groups[i].Instance, "ElementName") == name {
+			return &groups[i], nil
+		}
+	}
+	return nil, fmt.Errorf("%s %s %w", className, name, ErrNotFound)
+}
+
+// associatedPathsCtx is AssociatorNamesCtx returning instance paths, with
+// empty roles meaning any role.
+func (smis *SMIS) associatedPathsCtx(ctx context.Context, instanceName *gowbem.InstanceName, assocClass, resultClass, role, resultRole string) ([]gowbem.InstancePath, error) {
+	var rolePtr, resultRolePtr *string
+	if role != "" {
+		rolePtr = &role
+	}
+	if resultRole != "" {
+		resultRolePtr = &resultRole
+	}
+
+	objects, err := smis.AssociatorNamesCtx(ctx, instanceName, assocClass, resultClass, rolePtr, resultRolePtr)
+	if err != nil {
+		return nil, err
+	}
+	var paths []gowbem.InstancePath
+	for _, object := range objects {
+		if object.InstancePath != nil {
+			paths = append(paths, *object.InstancePath)
+		}
+	}
+	return paths, nil
+}
+
+func (smis *SMIS) groupMembersCtx(ctx context.Context, group *gowbem.InstanceName, memberClass string) ([]gowbem.InstancePath, error) {
+	return smis.associatedPathsCtx(ctx, group, memberOfCollection, memberClass, "Collection", "Member")
+}
+
+func (smis *SMIS) groupParentsCtx(ctx context.Context, group *gowbem.InstanceName) ([]gowbem.InstancePath, error) {
+	return smis.associatedPathsCtx(ctx, group, memberOfCollection, group.ClassName, "Member", "Collection")
+}
+
+func (smis *SMIS) groupMaskingViewsCtx(ctx context.Context, group *gowbem.InstanceName) ([]gowbem.InstancePath, error) {
+	return smis.associatedPathsCtx(ctx, group, "", MaskingViewClass, "", "")
+}
+
+///////////////////////////////////////////////////////////////
+//      RESOLVE the members and views of each group type     //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) resolveStorageGroupCtx(ctx context.Context, group *gowbem.ValueObjectWithPath) (*StorageGroup, error) {
+	name := group.InstancePath.InstanceName
+	sg := &StorageGroup{
+		InstancePath: group.InstancePath,
+		Name:         propertyString(group.Instance, "ElementName"),
+		InstanceID:   propertyString(group.Instance, "InstanceID"),
+	}
+
+	var err error
+	if sg.Volumes, err = smis.groupMembersCtx(ctx, name, "CIM_StorageVolume"); err != nil {
+		return nil, err
+	}
+	if sg.Children, err = smis.groupMembersCtx(ctx, name, StorageGroupClass); err != nil {
+		return nil, err
+	}
+	if sg.Parents, err = smis.groupParentsCtx(ctx, name); err != nil {
+		return nil, err
+	}
+	if sg.MaskingViews, err = smis.groupMaskingViewsCtx(ctx, name); err != nil {
+		return nil, err
+	}
+	return sg, nil
+}
+
+func (smis *SMIS) resolvePortGroupCtx(ctx context.Context, group *gowbem.ValueObjectWithPath) (*PortGroup, error) {
+	name := group.InstancePath.InstanceName
+	pg := &PortGroup{
+		InstancePath: group.InstancePath,
+		Name:         propertyString(group.Instance, "ElementName"),
+		InstanceID:   propertyString(group.Instance, "InstanceID"),
+	}
+
+	var err error
+	if pg.Ports, err = smis.groupMembersCtx(ctx, name, "CIM_SCSIProtocolEndpoint"); err != nil {
+		return nil, err
+	}
+	if pg.MaskingViews, err = smis.groupMaskingViewsCtx(ctx, name); err != nil {
+		return nil, err
+	}
+	return pg, nil
+}
+
+func (smis *SMIS) resolveInitiatorGroupCtx(ctx context.Context, group *gowbem.ValueObjectWithPath) (*InitiatorGroup, error) {
+	name := group.InstancePath.InstanceName
+	ig := &InitiatorGroup{
+		InstancePath: group.InstancePath,
+		Name:         propertyString(group.Instance, "ElementName"),
+		InstanceID:   propertyString(group.Instance, "InstanceID"),
+	}
+
+	var err error
+	if ig.Initiators, err = smis.groupMembersCtx(ctx, name, "SE_StorageHardwareID"); err != nil {
+		return nil, err
+	}
+	if ig.Children, err = smis.groupMembersCtx(ctx, name, InitiatorGroupClass); err != nil {
+		return nil, err
+	}
+	if ig.Parents, err = smis.groupParentsCtx(ctx, name); err != nil {
+		return nil, err
+	}
+	if ig.MaskingViews, err = smis.groupMaskingViewsCtx(ctx, name); err != nil {
+		return nil, err
+	}
+	return ig, nil
+}
+
+///////////////////////////////////////////////////////////////
+//      Index of the members, parents and views of every     //
+//      group, used to list group details in a few calls     //
+///////////////////////////////////////////////////////////////
+
+type groupIndex struct {
+	members map[string][]gowbem.InstancePath
+	parents map[string][]gowbem.InstancePath
+	views   map[string][]gowbem.InstancePath
+}
+
+// instanceNameKey gives equal instance names the same string, whatever the
+// order of their key bindings.
+func instanceNameKey(name *gowbem.InstanceName) string {
+	keys := make([]string, 0, len(name.KeyBinding))
+	for _, key := range name.KeyBinding {
+		value := ""
+		if key.KeyValue != nil {
+			value = key.KeyValue.KeyValue
+		}
+		keys = append(keys, key.Name+"="+value)
+	}
+	sort.Strings(keys)
+	return name.ClassName + "." + strings.Join(keys, ",")
+}
+
+// newGroupIndex indexes the memberships of the given groups.  Memberships of
+// any other collection, such as the groups of other arrays, are ignored.
+func newGroupIndex(groups []gowbem.ValueObjectWithPath, memberships []Association) *groupIndex {
+	index := &groupIndex{
+		members: make(map[string][]gowbem.InstancePath),
+		parents: make(map[string][]gowbem.InstancePath),
+		views:   make(map[string][]gowbem.InstancePath),
+	}
+	known := make(map[string]bool)
+	for _, group := range groups {
+		known[instanceNameKey(group.InstancePath.InstanceName)] = true
+	}
+
+	for i := range memberships {
+		collection, err := memberships[i].Reference("Collection")
+		if err != nil {
+			continue
+		}
+		member, err := memberships[i].Reference("Member")
+		if err != nil {
+			continue
+		}
+		collectionKey, memberKey := instanceNameKey(collection), instanceNameKey(member)
+		if known[collectionKey] {
+			index.members[collectionKey] = append(index.members[collectionKey], gowbem.InstancePath{InstanceName: member})
+		}
+		if known[memberKey] && known[collectionKey] {
+			index.parents[memberKey] = append(index.parents[memberKey], gowbem.InstancePath{InstanceName: collection})
+		}
+	}
+	return index
+}
+
+// groupIndexCtx reads every group membership in one enumeration of the
+// association class, and the groups of every masking view with one call per
+// view.
+func (smis *SMIS) groupIndexCtx(ctx context.Context, systemInstance *gowbem.InstanceName, groups []gowbem.ValueObjectWithPath, className string) (*groupIndex, error) {
+	memberships, err := smis.EnumerateAssociationsCtx(ctx, memberOfCollection)
+	if err != nil {
+		return nil, err
+	}
+	index := newGroupIndex(groups, memberships)
+
+	views, err := smis.GetMaskingViewsCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	for _, view := range views {
+		if view.InstancePath == nil {
+			continue
+		}
+		viewGroups, err := smis.associatedPathsCtx(ctx, view.InstancePath.InstanceName, "", className, "", "")
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range viewGroups {
+			key := instanceNameKey(group.InstanceName)
+			index.views[key] = append(index.views[key], *view.InstancePath)
+		}
+	}
+	return index, nil
+}
+
+// split returns the members of a group of the given class, which are its
+// child groups, and the rest.
+func (index *groupIndex) split(group *gowbem.InstanceName, childClass string) (children, others []gowbem.InstancePath) {
+	for _, member := range index.members[instanceNameKey(group)] {
+		if member.InstanceName.ClassName == childClass {
+			children = append(children, member)
+		} else {
+			others = append(others, member)
+		}
+	}
+	return children, others
+}
+
+func (index *groupIndex) storageGroup(group *gowbem.ValueObjectWithPath) StorageGroup {
+	name := group.InstancePath.InstanceName
+	sg := StorageGroup{
+		InstancePath: group.InstancePath,
+		Name:         propertyString(group.Instance, "ElementName"),
+		InstanceID:   propertyString(group.Instance, "InstanceID"),
+		Parents:      index.parents[instanceNameKey(name)],
+		MaskingViews: index.views[instanceNameKey(name)],
+	}
+	sg.Children, sg.Volumes = index.split(name, StorageGroupClass)
+	return sg
+}
+
+func (index *groupIndex) portGroup(group *gowbem.ValueObjectWithPath) PortGroup {
+	name := group.InstancePath.InstanceName
+	return PortGroup{
+		InstancePath: group.InstancePath,
+		Name:         propertyString(group.Instance, "ElementName"),
+		InstanceID:   propertyString(group.Instance, "InstanceID"),
+		Ports:        index.members[instanceNameKey(name)],
+		MaskingViews: index.views[instanceNameKey(name)],
+	}
+}
+
+func (index *groupIndex) initiatorGroup(group *gowbem.ValueObjectWithPath) InitiatorGroup {
+	name := group.InstancePath.InstanceName
+	ig := InitiatorGroup{
+		InstancePath: group.InstancePath,
+		Name:         propertyString(group.Instance, "ElementName"),
+		InstanceID:   propertyString(group.Instance, "InstanceID"),
+		Parents:      index.parents[instanceNameKey(name)],
+		MaskingViews: index.views[instanceNameKey(name)],
+	}
+	ig.Children, ig.Initiators = index.split(name, InitiatorGroupClass)
+	return ig
+}
+
+///////////////////////////////////////////////////////////////
+//            GET a Storage Group by name                    //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetStorageGroupByName(systemInstance *gowbem.InstanceName, name string) (*StorageGroup, error) {
+	return smis.GetStorageGroupByNameCtx(context.Background(), systemInstance, name)
+}
+
+func (smis *SMIS) GetStorageGroupByNameCtx(ctx context.Context, systemInstance *gowbem.InstanceName, name string) (*StorageGroup, error) {
+	group, err := smis.findGroupByNameCtx(ctx, systemInstance, StorageGroupClass, name)
+	if err != nil {
+		return nil, err
+	}
+	return smis.resolveStorageGroupCtx(ctx, group)
+}
+
+///////////////////////////////////////////////////////////////
+//            GET a Port Group by name                       //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetPortGroupByName(systemInstance *gowbem.InstanceName, name string) (*PortGroup, error) {
+	return smis.GetPortGroupByNameCtx(context.Background(), systemInstance, name)
+}
+
+func (smis *SMIS) GetPortGroupByNameCtx(ctx context.Context, systemInstance *gowbem.InstanceName, name string) (*PortGroup, error) {
+	group, err := smis.findGroupByNameCtx(ctx, systemInstance, PortGroupClass, name)
+	if err != nil {
+		return nil, err
+	}
+	return smis.resolvePortGroupCtx(ctx, group)
+}
+
+///////////////////////////////////////////////////////////////
+//            GET an Initiator Group by name                 //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetInitiatorGroupByName(systemInstance *gowbem.InstanceName, name string) (*InitiatorGroup, error) {
+	return smis.GetInitiatorGroupByNameCtx(context.Background(), systemInstance, name)
+}
+
+func (smis *SMIS) GetInitiatorGroupByNameCtx(ctx context.Context, systemInstance *gowbem.InstanceName, name string) (*InitiatorGroup, error) {
+	group, err := smis.findGroupByNameCtx(ctx, systemInstance, InitiatorGroupClass, name)
+	if err != nil {
+		return nil, err
+	}
+	return smis.resolveInitiatorGroupCtx(ctx, group)
+}
+
+///////////////////////////////////////////////////////////////
+//         GET the details of every Storage Group            //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListStorageGroupDetails(systemInstance *gowbem.InstanceName) ([]StorageGroup, error) {
+	return smis.ListStorageGroupDetailsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) ListStorageGroupDetailsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]StorageGroup, error) {
+	groups, err := smis.listGroupsCtx(ctx, systemInstance, StorageGroupClass)
+	if err != nil {
+		return nil, err
+	}
+	index, err := smis.groupIndexCtx(ctx, systemInstance, groups, StorageGroupClass)
+	if err != nil {
+		return nil, err
+	}
+
+	var details []StorageGroup
+	for i := range groups {
+		details = append(details, index.storageGroup(&groups[i]))
+	}
+	return details, nil
+}
+
+///////////////////////////////////////////////////////////////
+//         GET the details of every Port Group               //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListPortGroupDetails(systemInstance *gowbem.InstanceName) ([]PortGroup, error) {
+	return smis.ListPortGroupDetailsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) ListPortGroupDetailsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]PortGroup, error) {
+	groups, err := smis.listGroupsCtx(ctx, systemInstance, PortGroupClass)
+	if err != nil {
+		return nil, err
+	}
+	index, err := smis.groupIndexCtx(ctx, systemInstance, groups, PortGroupClass)
+	if err != nil {
+		return nil, err
+	}
+
+	var details []PortGroup
+	for i := range groups {
+		details = append(details, index.portGroup(&groups[i]))
+	}
+	return details, nil
+}
+
+///////////////////////////////////////////////////////////////
+//         GET the details of every Initiator Group          //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListInitiatorGroupDetails(systemInstance *gowbem.InstanceName) ([]InitiatorGroup, error) {
+	return smis.ListInitiatorGroupDetailsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) ListInitiatorGroupDetailsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]InitiatorGroup, error) {
+	groups, err := smis.listGroupsCtx(ctx, systemInstance, InitiatorGroupClass)
+	if err != nil {
+		return nil, err
+	}
+	index, err := smis.groupIndexCtx(ctx, systemInstance, groups, InitiatorGroupClass)
+	if err != nil {
+		return nil, err
+	}
+
+	var details []InitiatorGroup
+	for i := range groups {
+		details = append(details, index.initiatorGroup(&groups[i]))
+	}
+	return details, nil
+}
+
+///////////////////////////////////////////////////////////////
+//         CREATE a cascaded (parent) Storage Group          //
+//                                                           //
+//   The parent holds child storage groups instead of        //
+//   volumes; each child keeps its own SLO.  Only one level  //
+//   of cascading is allowed.                                //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) CreateCascadedStorageGroup(systemInstance *gowbem.InstanceName, groupName string, children []gowbem.InstancePath) (*gowbem.InstancePath, error) {
+	return smis.CreateCascadedStorageGroupCtx(context.Background(), systemInstance, groupName, children)
+}
+
+func (smis *SMIS) CreateCascadedStorageGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, groupName string, children []gowbem.InstancePath) (*gowbem.InstancePath, error) {
+	for idx := range children {
+		if err := smis.checkChildStorageGroupCtx(ctx, &children[idx]); err != nil {
+			return nil, err
+		}
+	}
+
+	// the journal deletes the new parent if the children cannot be added
+	journal, err := smis.NewJournal("")
+	if err != nil {
+		return nil, err
+	}
+	parent, err := journal.CreateGroupCtx(ctx, systemInstance, groupName, GroupTypeStorage)
+	if err == nil && len(children) > 0 {
+		err = smis.AddMembersToGroupCtx(ctx, systemInstance, parent, children)
+	}
+	if err = journal.finish(err); err != nil {
+		return nil, err
+	}
+	return parent, nil
+}
+
+// checkChildStorageGroupCtx rejects groups that are already parents, since
+// cascading is limited to a single level, and groups that already have a
+// parent, since a child belongs to only one.
+func (smis *SMIS) checkChildStorageGroupCtx(ctx context.Context, child *gowbem.InstancePath) error {
+	grandChildren, err := smis.groupMembersCtx(ctx, child.InstanceName, StorageGroupClass)
+	if err != nil {
+		return err
+	}
+	if len(grandChildren) > 0 {
+		return errors.New("Storage group is already a parent and cannot be a child")
+	}
+	parents, err := smis.groupParentsCtx(ctx, child.InstanceName)
+	if err != nil {
+		return err
+	}
+	if len(parents) > 0 {
+		return fmt.Errorf("Storage group is already the child of another group: %w", ErrInUse)
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+//       ADD a child Storage Group to a parent group         //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) AddChildStorageGroup(systemInstance *gowbem.InstanceName, parent, child *gowbem.InstancePath) error {
+	return smis.AddChildStorageGroupCtx(context.Background(), systemInstance, parent, child)
+}
+
+func (smis *SMIS) AddChildStorageGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, parent, child *gowbem.InstancePath) error {
+	volumes, err := smis.groupMembersCtx(ctx, parent.InstanceName, "CIM_StorageVolume")
+	if err != nil {
+		return err
+	}
+	if len(volumes) > 0 {
+		return errors.New("Storage group holds volumes and cannot be a parent")
+	}
+	parents, err := smis.groupParentsCtx(ctx, parent.InstanceName)
+	if err != nil {
+		return err
+	}
+	if len(parents) > 0 {
+		return errors.New("Storage group is already a child and cannot be a parent")
+	}
+	if err = smis.checkChildStorageGroupCtx(ctx, child); err != nil {
+		return err
+	}
+	return smis.AddMembersToGroupCtx(ctx, systemInstance, parent, []gowbem.InstancePath{*child})
+}
+
+///////////////////////////////////////////////////////////////
+//     REMOVE a child Storage Group from a parent group      //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) RemoveChildStorageGroup(systemInstance *gowbem.InstanceName, parent, child *gowbem.InstancePath) error {
+	return smis.RemoveChildStorageGroupCtx(context.Background(), systemInstance, parent, child)
+}
+
+func (smis *SMIS) RemoveChildStorageGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, parent, child *gowbem.InstancePath) error {
+	return smis.RemoveMembersFromGroupCtx(ctx, systemInstance, parent, []gowbem.InstancePath{*child})
+}
+
+///////////////////////////////////////////////////////////////
+//       GET the child Storage Groups of a parent group      //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListChildStorageGroups(parent *gowbem.InstanceName) ([]gowbem.InstancePath, error) {
+	return smis.ListChildStorageGroupsCtx(context.Background(), parent)
+}
+
+func (smis *SMIS) ListChildStorageGroupsCtx(ctx context.Context, parent *gowbem.InstanceName) ([]gowbem.InstancePath, error) {
+	return smis.groupMembersCtx(ctx, parent, StorageGroupClass)
+}
@@ -0,0 +1,202 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+)
+
+func TestListStorageGroupDetails(t *testing.T) {
+	groups, err := smis.ListStorageGroupDetails(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	for _, sg := range groups {
+		fmt.Printf("%s volumes=%d children=%d views=%d\n", sg.Name, len(sg.Volumes), len(sg.Children), len(sg.MaskingViews))
+	}
+}
+
+func TestGetStorageGroupByName(t *testing.T) {
+	group, err := smis.PostCreateGroup(testingInstance, "govmax_test_sg_lookup", 4)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, group, true)
+
+	sg, err := smis.GetStorageGroupByName(testingInstance, "govmax_test_sg_lookup")
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if len(sg.Volumes) != 0 || len(sg.MaskingViews) != 0 {
+		t.Log("new group is not empty")
+		t.Fail()
+	}
+
+	_, err = smis.GetStorageGroupByName(testingInstance, "govmax_test_no_such_sg")
+	if !errors.Is(err, ErrNotFound) {
+		t.Log("expected ErrNotFound, got", err)
+		t.Fail()
+	}
+}
+
+func TestListPortGroupDetails(t *testing.T) {
+	groups, err := smis.ListPortGroupDetails(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	for _, pg := range groups {
+		fmt.Printf("%s ports=%d views=%d\n", pg.Name, len(pg.Ports), len(pg.MaskingViews))
+	}
+}
+
+func TestListInitiatorGroupDetails(t *testing.T) {
+	groups, err := smis.ListInitiatorGroupDetails(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	for _, ig := range groups {
+		fmt.Printf("%s initiators=%d views=%d\n", ig.Name, len(ig.Initiators), len(ig.MaskingViews))
+	}
+	if len(groups) == 0 {
+		return
+	}
+
+	found, err := smis.GetInitiatorGroupByName(testingInstance, groups[0].Name)
+	if err != nil || found.InstanceID != groups[0].InstanceID {
+		t.Log("lookup by name failed", err)
+		t.Fail()
+	}
+}
+
+func TestCascadedStorageGroup(t *testing.T) {
+	child, err := smis.CreateGroup(testingInstance, "govmax_test_child_sg", GroupTypeStorage)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, child, true)
+
+	parent, err := smis.CreateCascadedStorageGroup(testingInstance, "govmax_test_parent_sg", nil)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, parent, true)
+
+	if err = smis.AddChildStorageGroup(testingInstance, parent, child); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	children, err := smis.ListChildStorageGroups(parent.InstanceName)
+	if err != nil || len(children) != 1 {
+		t.Log("child not listed", err)
+		t.Fail()
+	}
+
+	if err = smis.AddChildStorageGroup(testingInstance, child, parent); err == nil {
+		t.Log("a parent group was accepted as a child")
+		t.Fail()
+	}
+
+	other, err := smis.CreateCascadedStorageGroup(testingInstance, "govmax_test_other_parent_sg", nil)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, other, true)
+	if err = smis.AddChildStorageGroup(testingInstance, other, child); !errors.Is(err, ErrInUse) {
+		t.Log("a child with a parent was accepted by another parent", err)
+		t.Fail()
+	}
+
+	if err = smis.RemoveChildStorageGroup(testingInstance, parent, child); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+}
+
+func TestAddChildToChildGroup(t *testing.T) {
+	var methods []string
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		method := r.Header.Get("CIMMethod")
+		methods = append(methods, method)
+		if strings.Contains(string(body), `<IPARAMVALUE NAME="Role"><VALUE>Member</VALUE></IPARAMVALUE>`) {
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="AssociatorNames"><IRETURNVALUE><OBJECTPATH>` +
+				`<INSTANCEPATH><NAMESPACEPATH/><INSTANCENAME CLASSNAME="SE_DeviceMaskingGroup"><KEYBINDING NAME="InstanceID"><KEYVALUE>top_SG</KEYVALUE></KEYBINDING></INSTANCENAME></INSTANCEPATH>` +
+				`</OBJECTPATH></IRETURNVALUE></IMETHODRESPONSE>`)))
+			return
+		}
+		w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="` + method + `"><IRETURNVALUE></IRETURNVALUE></IMETHODRESPONSE>`)))
+	})
+	defer server.Close()
+
+	parent := &gowbem.InstancePath{InstanceName: testGroupName(StorageGroupClass, "middle_SG")}
+	child := &gowbem.InstancePath{InstanceName: testGroupName(StorageGroupClass, "bottom_SG")}
+	if err := client.AddChildStorageGroup(testVolumeName, parent, child); err == nil {
+		t.Error("a child group was accepted as a parent")
+	}
+	for _, method := range methods {
+		if method != "AssociatorNames" {
+			t.Errorf("group changed although the parent is a child: %v", methods)
+		}
+	}
+}
+
+func testGroupName(className, instanceID string) *gowbem.InstanceName {
+	return &gowbem.InstanceName{
+		ClassName:  className,
+		KeyBinding: []gowbem.KeyBinding{{Name: "InstanceID", KeyValue: &gowbem.KeyValue{instanceID}}},
+	}
+}
+
+func testMembership(collection, member *gowbem.InstanceName) Association {
+	return Association{References: []cimPropertyReference{
+		{Name: "Collection", InstanceName: collection},
+		{Name: "Member", InstanceName: member},
+	}}
+}
+
+func TestNewGroupIndex(t *testing.T) {
+	parent := testGroupName(StorageGroupClass, "SYMMETRIX-+-1380-+-app_sg")
+	child := testGroupName(StorageGroupClass, "SYMMETRIX-+-1380-+-data_sg")
+	other := testGroupName(StorageGroupClass, "SYMMETRIX-+-9999-+-other_sg")
+	groups := []gowbem.ValueObjectWithPath{
+		{InstancePath: &gowbem.InstancePath{InstanceName: parent}, Instance: &gowbem.Instance{}},
+		{InstancePath: &gowbem.InstancePath{InstanceName: child}, Instance: &gowbem.Instance{}},
+	}
+	index := newGroupIndex(groups, []Association{
+		testMembership(parent, child),
+		testMembership(child, testVolumeName),
+		testMembership(other, testVolumeName),
+	})
+
+	sg := index.storageGroup(&groups[0])
+	if len(sg.Children) != 1 || len(sg.Volumes) != 0 || len(sg.Parents) != 0 {
+		t.Errorf("unexpected parent group %+v", sg)
+	}
+	sg = index.storageGroup(&groups[1])
+	if len(sg.Volumes) != 1 || len(sg.Children) != 0 || len(sg.Parents) != 1 ||
+		!sameInstanceName(sg.Parents[0].InstanceName, parent) {
+		t.Errorf("unexpected child group %+v", sg)
+	}
+}
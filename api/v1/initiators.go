@@ -0,0 +1,187 @@
+package apiv1
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+////////////////////////////////////////////////////////////////
+//    A host initiator registered on the array, with the      //
+//       groups it belongs to and the ports it is on          //
+////////////////////////////////////////////////////////////////
+
+type Initiator struct {
+	InstancePath    *gowbem.InstancePath
+	StorageID       string
+	Type            HardwareIDType
+	HostAlias       string
+	InitiatorGroups []gowbem.InstancePath
+	LoggedInPorts   []PortValues
+}
+
+// Orphaned reports whether the initiator is in no initiator group, so no
+// masking view can use it.
+func (i *Initiator) Orphaned() bool {
+	return len(i.InitiatorGroups) == 0
+}
+
+// LoggedIn reports whether the initiator is logged in to at least one front
+// end port of the array.
+func (i *Initiator) LoggedIn() bool {
+	return len(i.LoggedInPorts) > 0
+}
+
+var initiatorProperties = []string{"StorageID", "IDType", "ElementName"}
+
+// initiatorLoginWorkers bounds the port login lookups ListInitiators runs
+// at once; the provider answers one EMCGetTargetEndpoints per initiator.
+const initiatorLoginWorkers = 8
+
+// decodeInitiator builds an Initiator from a hardware ID instance.
+func decodeInitiator(id *gowbem.ValueObjectWithPath) *Initiator {
+	initiator := &Initiator{
+		InstancePath: id.InstancePath,
+		StorageID:    propertyString(id.Instance, "StorageID"),
+		Type:         HardwareIDType(propertyUint64(id.Instance, "IDType")),
+		HostAlias:    propertyString(id.Instance, "ElementName"),
+	}
+	if initiator.Type.Validate() != nil {
+		initiator.Type = HardwareIDTypeOf(initiator.StorageID)
+	}
+	return initiator
+}
+
+// setLoginsCtx looks up the ports the initiator is logged in to.
+func (smis *SMIS) setLoginsCtx(ctx context.Context, service *gowbem.InstanceName, initiator *Initiator) error {
+	logins, err := smis.portLoginsCtx(ctx, service, initiator.InstancePath, initiator.StorageID)
+	if err != nil {
+		return fmt.Errorf("Initiator %s: %w", initiator.StorageID, err)
+	}
+	initiator.LoggedInPorts = logins
+	return nil
+}
+
+// initiatorGroupsOf indexes the initiator groups of every member, from an
+// enumeration of the group memberships.
+func initiatorGroupsOf(memberships []Association) map[string][]gowbem.InstancePath {
+	groups := make(map[string][]gowbem.InstancePath)
+	for i := range memberships {
+		collection, err := memberships[i].Reference("Collection")
+		if err != nil || collection.ClassName != InitiatorGroupClass {
+			continue
+		}
+		member, err := memberships[i].Reference("Member")
+		if err != nil {
+			continue
+		}
+		key := instanceNameKey(member)
+		groups[key] = append(groups[key], gowbem.InstancePath{InstanceName: collection})
+	}
+	return groups
+}
+
+///////////////////////////////////////////////////////////////
+//        GET the details of every Initiator                 //
+//                                                           //
+//   Group memberships are read in one enumeration and the   //
+//   port logins looked up a few initiators at a time.       //
+//   Initiators whose logins cannot be read are left out     //
+//   and reported in a *PartialError returned with the rest. //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListInitiators(systemInstance *gowbem.InstanceName) ([]Initiator, error) {
+	return smis.ListInitiatorsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) ListInitiatorsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]Initiator, error) {
+	service, err := smis.GetStorageHardwareIDManagementServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := smis.AssociatorInstancesCtx(ctx, service, "", "SE_StorageHardwareID", nil, nil, false, initiatorProperties)
+	if err != nil {
+		return nil, err
+	}
+	memberships, err := smis.EnumerateAssociationsCtx(ctx, memberOfCollection)
+	if err != nil {
+		return nil, err
+	}
+	groupsOf := initiatorGroupsOf(memberships)
+
+	var decoded []*Initiator
+	for idx := range ids {
+		if ids[idx].InstancePath == nil {
+			continue
+		}
+		initiator := decodeInitiator(&ids[idx])
+		initiator.InitiatorGroups = groupsOf[instanceNameKey(initiator.InstancePath.InstanceName)]
+		decoded = append(decoded, initiator)
+	}
+
+	loginErrs := make([]error, len(decoded))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < initiatorLoginWorkers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range next {
+				loginErrs[idx] = smis.setLoginsCtx(ctx, service, decoded[idx])
+			}
+		}()
+	}
+	for idx := range decoded {
+		next <- idx
+	}
+	close(next)
+	wg.Wait()
+
+	if err = ctx.Err(); err != nil {
+		return nil, err
+	}
+	var initiators []Initiator
+	var errs []error
+	for idx, initiator := range decoded {
+		if loginErrs[idx] != nil {
+			errs = append(errs, loginErrs[idx])
+			continue
+		}
+		initiators = append(initiators, *initiator)
+	}
+	return initiators, partialError("ListInitiators", errs)
+}
+
+///////////////////////////////////////////////////////////////
+//        GET an Initiator by its WWN or IQN                 //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetInitiatorByWWN(systemInstance *gowbem.InstanceName, storageID string) (*Initiator, error) {
+	return smis.GetInitiatorByWWNCtx(context.Background(), systemInstance, storageID)
+}
+
+func (smis *SMIS) GetInitiatorByWWNCtx(ctx context.Context, systemInstance *gowbem.InstanceName, storageID string) (*Initiator, error) {
+	service, err := smis.GetStorageHardwareIDManagementServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	path, err := smis.GetStorageHardwareIDByStorageIDCtx(ctx, systemInstance, storageID)
+	if err != nil {
+		return nil, err
+	}
+	instance, err := smis.GetInstanceCtx(ctx, path.InstanceName, false, initiatorProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	initiator := decodeInitiator(&gowbem.ValueObjectWithPath{InstancePath: path, Instance: instance})
+	if initiator.InitiatorGroups, err = smis.associatedPathsCtx(ctx, path.InstanceName, memberOfCollection, InitiatorGroupClass, "Member", "Collection"); err != nil {
+		return nil, err
+	}
+	if err = smis.setLoginsCtx(ctx, service, initiator); err != nil {
+		return nil, err
+	}
+	return initiator, nil
+}
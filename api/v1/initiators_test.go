@@ -0,0 +1,85 @@
+package apiv1
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+func TestListInitiators(t *testing.T) {
+	initiators, err := smis.ListInitiators(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	for _, initiator := range initiators {
+		t.Logf("%s %s alias=%s groups=%d ports=%d loggedIn=%v orphaned=%v", initiator.StorageID, initiator.Type, initiator.HostAlias,
+			len(initiator.InitiatorGroups), len(initiator.LoggedInPorts), initiator.LoggedIn(), initiator.Orphaned())
+	}
+	if len(initiators) == 0 {
+		return
+	}
+
+	initiator, err := smis.GetInitiatorByWWN(testingInstance, initiators[0].StorageID)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if initiator.StorageID != initiators[0].StorageID {
+		t.Log("expected " + initiators[0].StorageID + ", got " + initiator.StorageID)
+		t.Fail()
+	}
+}
+
+func TestGetInitiatorByWWNNotFound(t *testing.T) {
+	_, err := smis.GetInitiatorByWWN(testingInstance, "0000000000000000")
+	if !errors.Is(err, ErrNotFound) {
+		t.Log("expected ErrNotFound, got", err)
+		t.Fail()
+	}
+}
+
+func TestInitiatorGroupsOf(t *testing.T) {
+	id := testGroupName("SE_StorageHardwareID", "W-+-10000000C94E5D22")
+	ig := testGroupName(InitiatorGroupClass, "SYMMETRIX-+-1380-+-host01_IG")
+	sg := testGroupName(StorageGroupClass, "SYMMETRIX-+-1380-+-host01_SG")
+	groups := initiatorGroupsOf([]Association{
+		testMembership(ig, id),
+		testMembership(sg, testVolumeName),
+	})
+
+	found := groups[instanceNameKey(id)]
+	if len(found) != 1 || !sameInstanceName(found[0].InstanceName, ig) {
+		t.Log("unexpected groups", found)
+		t.Fail()
+	}
+	if len(groups) != 1 {
+		t.Log("storage group membership indexed", groups)
+		t.Fail()
+	}
+}
+
+func TestPortLoginsStorageID(t *testing.T) {
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(cimMessage(`<METHODRESPONSE NAME="EMCGetTargetEndpoints"><RETURNVALUE PARAMTYPE="uint32"><VALUE>0</VALUE></RETURNVALUE>` +
+			`<PARAMVALUE NAME="TargetEndpoints"><VALUE.REFARRAY><VALUE.REFERENCE><INSTANCEPATH><NAMESPACEPATH/>` +
+			`<INSTANCENAME CLASSNAME="Symm_FCSCSIProtocolEndpoint"><KEYBINDING NAME="SystemName"><KEYVALUE>SYMMETRIX-+-000196701380-+-FA-1D-+-4</KEYVALUE></KEYBINDING></INSTANCENAME>` +
+			`</INSTANCEPATH></VALUE.REFERENCE></VALUE.REFARRAY></PARAMVALUE></METHODRESPONSE>`)))
+	})
+	defer server.Close()
+
+	// The InstanceID of a hardware ID does not have to end in the WWN.
+	id := &gowbem.InstancePath{InstanceName: testGroupName("SE_StorageHardwareID", "10000000C94E5D22-+-host01")}
+	logins, err := client.portLoginsCtx(context.Background(), testVolumeName, id, "10000000c94e5d22")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(logins) != 1 || logins[0].WWN != "10000000c94e5d22" || logins[0].Director != "FA-1D" || logins[0].PortNumber != "4" {
+		t.Errorf("unexpected logins %+v", logins)
+	}
+}
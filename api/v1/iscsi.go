@@ -0,0 +1,149 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	hostv1 "github.com/emccode/govmax/host/v1"
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+////////////////////////////////////////////////////////////////
+//     An iSCSI target: its IQN and the IP interfaces and     //
+//           TCP port it is reached on                        //
+////////////////////////////////////////////////////////////////
+
+type ISCSITarget struct {
+	InstancePath *gowbem.InstancePath
+	Director     string
+	PortNumber   string
+	IQN          string
+	IPAddresses  []string
+	TCPPort      uint64
+	Status       PortStatus
+}
+
+// Portals returns the ip:port pairs a host discovers the target on.
+func (t *ISCSITarget) Portals() []string {
+	port := t.TCPPort
+	if port == 0 {
+		port = 3260
+	}
+	var portals []string
+	for _, ip := range t.IPAddresses {
+		if strings.Contains(ip, ":") {
+			ip = "[" + ip + "]"
+		}
+		portals = append(portals, fmt.Sprintf("%s:%d", ip, port))
+	}
+	return portals
+}
+
+// bindsToCtx returns the endpoints of resultClass the endpoint is bound to
+// through CIM_BindsTo, in either direction.
+func (smis *SMIS) bindsToCtx(ctx context.Context, endpoint *gowbem.InstanceName, resultClass string, properties []string) ([]gowbem.ValueObjectWithPath, error) {
+	return smis.AssociatorInstancesCtx(ctx, endpoint, "CIM_BindsTo", resultClass, nil, nil, false, properties)
+}
+
+// resolveISCSITargetCtx decodes an iSCSI endpoint and follows its bindings
+// down to the TCP endpoint and the IP interfaces under it.
+func (smis *SMIS) resolveISCSITargetCtx(ctx context.Context, endpoint *gowbem.ValueObjectWithPath) (*ISCSITarget, error) {
+	name := endpoint.InstancePath.InstanceName
+	systemName, err := keyString(name, "SystemName")
+	if err != nil {
+		return nil, err
+	}
+	director, port, err := ParseFrontEndPortName(systemName)
+	if err != nil {
+		return nil, err
+	}
+	target := &ISCSITarget{
+		InstancePath: endpoint.InstancePath,
+		Director:     director,
+		PortNumber:   port,
+		IQN:          propertyString(endpoint.Instance, "Name"),
+		Status:       PortStatus(propertyUint64(endpoint.Instance, "EnabledState")),
+	}
+
+	tcps, err := smis.bindsToCtx(ctx, name, "CIM_TCPProtocolEndpoint", []string{"PortNumber"})
+	if err != nil {
+		return nil, err
+	}
+	for _, tcp := range tcps {
+		if target.TCPPort == 0 {
+			target.TCPPort = propertyUint64(tcp.Instance, "PortNumber")
+		}
+		ips, err := smis.bindsToCtx(ctx, tcp.InstancePath.InstanceName, "CIM_IPProtocolEndpoint", []string{"IPv4Address", "IPv6Address"})
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if address := propertyString(ip.Instance, "IPv4Address"); address != "" {
+				target.IPAddresses = append(target.IPAddresses, address)
+			}
+			if address := propertyString(ip.Instance, "IPv6Address"); address != "" {
+				target.IPAddresses = append(target.IPAddresses, address)
+			}
+		}
+	}
+	return target, nil
+}
+
+///////////////////////////////////////////////////////////////
+//        GET the iSCSI targets of the array                 //
+//                                                           //
+//   Targets that cannot be decoded are left out and         //
+//   reported in a *PartialError returned with the rest.     //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListISCSITargets(systemInstance *gowbem.InstanceName) ([]ISCSITarget, error) {
+	return smis.ListISCSITargetsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) ListISCSITargetsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]ISCSITarget, error) {
+	storageProcs, err := smis.GetStorageProcessorSystemCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	// iSCSI directors are not front end adapters by EMCBSPElementType, so
+	// every director is asked for its iSCSI endpoints.
+	var targets []ISCSITarget
+	var errs []error
+	for _, sp := range storageProcs {
+		if sp.InstancePath == nil {
+			continue
+		}
+		endpoints, err := smis.AssociatorInstancesCtx(ctx, sp.InstancePath.InstanceName, "", "CIM_iSCSIProtocolEndpoint", nil, nil, false, []string{"Name", "EnabledState"})
+		if err != nil {
+			return nil, err
+		}
+		for idx := range endpoints {
+			if endpoints[idx].InstancePath == nil {
+				continue
+			}
+			target, err := smis.resolveISCSITargetCtx(ctx, &endpoints[idx])
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			targets = append(targets, *target)
+		}
+	}
+	return targets, partialError("ListISCSITargets", errs)
+}
+
+////////////////////////////////////////////////////////////////
+//             GET Baremetal iSCSI Initiator Name             //
+////////////////////////////////////////////////////////////////
+
+// GetBaremetalIQN returns the IQN open-iscsi logs in with on this host.
+func GetBaremetalIQN() (string, error) {
+	iqn, err := hostv1.New("").InitiatorIQN()
+	if errors.Is(err, hostv1.ErrNotFound) {
+		return "", fmt.Errorf("iSCSI InitiatorName of this host %w", ErrNotFound)
+	}
+	return iqn, err
+}
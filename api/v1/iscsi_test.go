@@ -0,0 +1,21 @@
+package apiv1
+
+import (
+	"testing"
+)
+
+func TestListISCSITargets(t *testing.T) {
+	targets, err := smis.ListISCSITargets(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	for _, target := range targets {
+		t.Logf("%s:%s %s %v %s", target.Director, target.PortNumber, target.IQN, target.Portals(), target.Status)
+		if target.IQN == "" {
+			t.Log("iSCSI target without IQN on " + target.Director)
+			t.Fail()
+		}
+	}
+}
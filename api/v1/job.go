@@ -0,0 +1,425 @@
+package apiv1
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+///////////////////////////////////////////////////////////////
+//         Progress reported while waiting for a job         //
+///////////////////////////////////////////////////////////////
+
+type JobProgress struct {
+	JobPath         *gowbem.InstancePath
+	State           string
+	PercentComplete int
+	Elapsed         time.Duration
+}
+
+///////////////////////////////////////////////////////////////
+//              Settings used to wait for a job              //
+//                                                           //
+//   The job is polled every InitialInterval, growing by     //
+//   Multiplier up to MaxInterval.  Waiting stops after      //
+//   Timeout (one hour by default; zero waits until the      //
+//   context is done).  Progress, when set, is called after  //
+//   every poll.  A suspended, service or query-pending job  //
+//   fails the wait at once unless WaitOnStalledJobs is set. //
+//   When the caller's context is cancelled the job is       //
+//   terminated on the array, unless KeepJobOnCancel is set; //
+//   a wait that only hits Timeout leaves the job running.   //
+///////////////////////////////////////////////////////////////
+
+type JobWaiter struct {
+	InitialInterval   time.Duration
+	MaxInterval       time.Duration
+	Multiplier        float64
+	Timeout           time.Duration
+	Progress          func(JobProgress)
+	KeepJobOnCancel   bool
+	WaitOnStalledJobs bool
+}
+
+var DefaultJobWaiter = JobWaiter{
+	InitialInterval: 500 * time.Millisecond,
+	MaxInterval:     10 * time.Second,
+	Multiplier:      1.5,
+	Timeout:         time.Hour,
+}
+
+// Job states that are still expected to reach a final state on their own.
+var jobInProgressStates = map[string]bool{
+	"NEW":           true,
+	"STARTING":      true,
+	"RUNNING":       true,
+	"SHUTTING_DOWN": true,
+}
+
+// Job states that need outside help to move on.  They are only waited on
+// when the waiter sets WaitOnStalledJobs.
+var jobStalledStates = map[string]bool{
+	"SUSPENDED":     true,
+	"SERVICE":       true,
+	"QUERY_PENDING": true,
+}
+
+func (w JobWaiter) withDefaults() JobWaiter {
+	if w.InitialInterval <= 0 {
+		w.InitialInterval = DefaultJobWaiter.InitialInterval
+	}
+	if w.MaxInterval < w.InitialInterval {
+		w.MaxInterval = w.InitialInterval
+	}
+	if w.Multiplier < 1 {
+		w.Multiplier = 1
+	}
+	return w
+}
+
+//////////////////////////////////////////////////////////////////
+//   SET the waiter used by WaitForJob and the helpers that     //
+//                   wait for array jobs                        //
+//////////////////////////////////////////////////////////////////
+
+func (smis *SMIS) SetJobWaiter(waiter JobWaiter) {
+	smis.lock.Lock()
+	defer smis.lock.Unlock()
+	smis.waiter = &waiter
+}
+
+func (smis *SMIS) getJobWaiter() JobWaiter {
+	smis.lock.Lock()
+	defer smis.lock.Unlock()
+	if smis.waiter == nil {
+		return DefaultJobWaiter
+	}
+	return *smis.waiter
+}
+
+///////////////////////////////////////////////////////////////
+//         WAIT for a job using the given settings           //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) WaitForJobWith(waiter JobWaiter, jobPath *gowbem.InstancePath, resultClass string) ([]gowbem.ObjectPath, error) {
+	return smis.WaitForJobWithCtx(context.Background(), waiter, jobPath, resultClass)
+}
+
+func (smis *SMIS) WaitForJobWithCtx(ctx context.Context, waiter JobWaiter, jobPath *gowbem.InstancePath, resultClass string) ([]gowbem.ObjectPath, error) {
+	waiter = waiter.withDefaults()
+	parent := ctx
+	if waiter.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, waiter.Timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	interval := waiter.InitialInterval
+	for {
+		job, status, err := smis.GetJobStatusCtx(ctx, jobPath)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, smis.abortJob(parent, waiter, jobPath, status, start, ctx.Err())
+			}
+			return nil, err
+		}
+		if waiter.Progress != nil {
+			waiter.Progress(JobProgress{
+				JobPath:         jobPath,
+				State:           status,
+				PercentComplete: int(propertyUint64(job, "PercentComplete")),
+				Elapsed:         time.Since(start),
+			})
+		}
+		if status == "COMPLETED" {
+			break
+		}
+		if !jobInProgressStates[status] && !(waiter.WaitOnStalledJobs && jobStalledStates[status]) {
+			return nil, newJobError(jobPath, job, status)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, smis.abortJob(parent, waiter, jobPath, status, start, ctx.Err())
+		}
+
+		interval = time.Duration(float64(interval) * waiter.Multiplier)
+		if interval > waiter.MaxInterval {
+			interval = waiter.MaxInterval
+		}
+	}
+
+	return smis.AssociatorNamesCtx(ctx, jobPath.InstanceName, "", resultClass, nil, nil)
+}
+
+// abortJob builds the error for a wait that stopped early.  The job is only
+// terminated when the caller's own context was cancelled and the waiter does
+// not keep it; running past the waiter's Timeout leaves the job on the array.
+// The caller's context is already done, so the request gets its own short
+// deadline.
+func (smis *SMIS) abortJob(parent context.Context, waiter JobWaiter, jobPath *gowbem.InstancePath, status string, start time.Time, ctxErr error) error {
+	elapsed := time.Since(start)
+	if waiter.KeepJobOnCancel || parent.Err() == nil {
+		return fmt.Errorf("Job %s after %s: %w", status, elapsed, ctxErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jobCancelTimeout)
+	defer cancel()
+	if err := smis.CancelJobCtx(ctx, jobPath); err != nil {
+		return fmt.Errorf("Job %s after %s: %w (terminate failed: %v)", status, elapsed, ctxErr, err)
+	}
+	return fmt.Errorf("Job %s after %s, terminated: %w", status, elapsed, ctxErr)
+}
+
+///////////////////////////////////////////////////////////////
+//      CANCEL or KILL a job with RequestStateChange         //
+///////////////////////////////////////////////////////////////
+
+// RequestedState values for CIM_ConcreteJob.RequestStateChange.
+const (
+	JobRequestTerminate = 4
+	JobRequestKill      = 5
+)
+
+// jobCancelTimeout bounds the terminate request sent after a wait is aborted.
+const jobCancelTimeout = 30 * time.Second
+
+// CancelJob asks the array to terminate the job cleanly.
+func (smis *SMIS) CancelJob(jobPath *gowbem.InstancePath) error {
+	return smis.CancelJobCtx(context.Background(), jobPath)
+}
+
+func (smis *SMIS) CancelJobCtx(ctx context.Context, jobPath *gowbem.InstancePath) error {
+	return smis.requestJobStateChangeCtx(ctx, jobPath, JobRequestTerminate)
+}
+
+// KillJob stops the job immediately, without any cleanup by the provider.
+func (smis *SMIS) KillJob(jobPath *gowbem.InstancePath) error {
+	return smis.KillJobCtx(context.Background(), jobPath)
+}
+
+func (smis *SMIS) KillJobCtx(ctx context.Context, jobPath *gowbem.InstancePath) error {
+	return smis.requestJobStateChangeCtx(ctx, jobPath, JobRequestKill)
+}
+
+func (smis *SMIS) requestJobStateChangeCtx(ctx context.Context, jobPath *gowbem.InstancePath, requestedState int) error {
+	var params []gowbem.IParamValue
+	params = append(params, gowbem.IParamValue{Name: "RequestedState", Value: &gowbem.Value{strconv.Itoa(requestedState)}})
+
+	retValue, _, err := smis.InvokeMethodCtx(ctx, jobPath.InstanceName, "RequestStateChange", params)
+	if err != nil {
+		return err
+	}
+	// 4096 means the provider accepted the request and is stopping the job.
+	if retValue != ReturnCompleted && retValue != ReturnJobStarted {
+		return NewMethodError("RequestStateChange", retValue)
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+//       Handle to an array job running in the background    //
+///////////////////////////////////////////////////////////////
+
+type Job struct {
+	Method  string
+	JobPath *gowbem.InstancePath
+
+	smis   *SMIS
+	done   chan struct{}
+	cancel context.CancelFunc
+	result []gowbem.ObjectPath
+	err    error
+}
+
+// startJob turns a method result into a Job.  Methods that complete without
+// starting an array job give a Job that is already done.
+func (smis *SMIS) startJob(res *methodResult) (*Job, error) {
+	job := &Job{
+		Method: res.method,
+		smis:   smis,
+		done:   make(chan struct{}),
+	}
+
+	switch res.retValue {
+	case ReturnCompleted:
+		job.result = outputReferences(res.retParams)
+		job.cancel = func() {}
+		close(job.done)
+		return job, nil
+	case ReturnJobStarted:
+		idx, err := FindJobIndex(res.retParams)
+		if err != nil {
+			return nil, err
+		}
+		job.JobPath = res.retParams[idx].ValueReference.InstancePath
+	default:
+		return nil, NewMethodError(res.method, res.retValue)
+	}
+
+	// Job.Cancel terminates the job itself, so the background wait must not.
+	waiter := smis.getJobWaiter()
+	waiter.KeepJobOnCancel = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	job.cancel = cancel
+	go func() {
+		defer close(job.done)
+		job.result, job.err = smis.WaitForJobWithCtx(ctx, waiter, job.JobPath, res.resultClass)
+	}()
+	return job, nil
+}
+
+// Done returns a channel that is closed once the job has finished.
+func (job *Job) Done() <-chan struct{} {
+	return job.done
+}
+
+// Status polls the array for the job's current state.
+func (job *Job) Status() (JobProgress, error) {
+	return job.StatusCtx(context.Background())
+}
+
+func (job *Job) StatusCtx(ctx context.Context) (JobProgress, error) {
+	progress := JobProgress{JobPath: job.JobPath}
+	if job.JobPath == nil {
+		progress.State = "COMPLETED"
+		progress.PercentComplete = 100
+		return progress, nil
+	}
+
+	instance, status, err := job.smis.GetJobStatusCtx(ctx, job.JobPath)
+	if err != nil {
+		return progress, err
+	}
+	progress.State = status
+	progress.PercentComplete = int(propertyUint64(instance, "PercentComplete"))
+	return progress, nil
+}
+
+// Wait blocks until the job finishes or ctx is done.  A done ctx only stops
+// this call from waiting; the job keeps being tracked.
+func (job *Job) Wait(ctx context.Context) ([]gowbem.ObjectPath, error) {
+	select {
+	case <-job.done:
+		return job.result, job.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Result returns the job's outcome without blocking, or ErrJobNotDone.
+func (job *Job) Result() ([]gowbem.ObjectPath, error) {
+	select {
+	case <-job.done:
+		return job.result, job.err
+	default:
+		return nil, ErrJobNotDone
+	}
+}
+
+// Cancel terminates the job on the array and stops tracking it; Wait and
+// Result then return an error wrapping context.Canceled.  Cancelling a job
+// that has already finished does nothing.
+func (job *Job) Cancel() error {
+	return job.CancelCtx(context.Background())
+}
+
+func (job *Job) CancelCtx(ctx context.Context) error {
+	select {
+	case <-job.done:
+		return nil
+	default:
+	}
+
+	err := job.smis.CancelJobCtx(ctx, job.JobPath)
+	job.cancel()
+	return err
+}
+
+///////////////////////////////////////////////////////////
+//         START creating Storage Volumes                //
+///////////////////////////////////////////////////////////
+
+func (smis *SMIS) StartPostVolumes(req *PostVolumesReq, systemInstance *gowbem.InstanceName) (*Job, error) {
+	return smis.StartPostVolumesCtx(context.Background(), req, systemInstance)
+}
+
+func (smis *SMIS) StartPostVolumesCtx(ctx context.Context, req *PostVolumesReq, systemInstance *gowbem.InstanceName) (*Job, error) {
+	res, err := smis.invokePostVolumesCtx(ctx, req, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	return smis.startJob(res)
+}
+
+///////////////////////////////////////////////////////////
+//         START creating a Masking View                 //
+///////////////////////////////////////////////////////////
+
+func (smis *SMIS) StartCreateMaskingView(systemInstance *gowbem.InstanceName, mvName string, sg, ig, pg *gowbem.InstancePath) (*Job, error) {
+	return smis.StartCreateMaskingViewCtx(context.Background(), systemInstance, mvName, sg, ig, pg)
+}
+
+func (smis *SMIS) StartCreateMaskingViewCtx(ctx context.Context, systemInstance *gowbem.InstanceName, mvName string, sg, ig, pg *gowbem.InstancePath) (*Job, error) {
+	res, err := smis.invokeCreateMaskingViewCtx(ctx, systemInstance, mvName, sg, ig, pg)
+	if err != nil {
+		return nil, err
+	}
+	return smis.startJob(res)
+}
+
+///////////////////////////////////////////////////////////
+//         START adding Members to a Group               //
+///////////////////////////////////////////////////////////
+
+func (smis *SMIS) StartAddMembersToGroup(systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) (*Job, error) {
+	return smis.StartAddMembersToGroupCtx(context.Background(), systemInstance, group, members)
+}
+
+func (smis *SMIS) StartAddMembersToGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) (*Job, error) {
+	res, err := smis.invokeAddMembersToGroupCtx(ctx, systemInstance, group, members)
+	if err != nil {
+		return nil, err
+	}
+	return smis.startJob(res)
+}
+
+///////////////////////////////////////////////////////////
+//         START deleting Storage Volumes                //
+///////////////////////////////////////////////////////////
+
+func (smis *SMIS) StartDeleteVol(systemInstance *gowbem.InstanceName, volumes []gowbem.InstancePath) (*Job, error) {
+	return smis.StartDeleteVolCtx(context.Background(), systemInstance, volumes)
+}
+
+func (smis *SMIS) StartDeleteVolCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volumes []gowbem.InstancePath) (*Job, error) {
+	res, err := smis.invokeDeleteVolCtx(ctx, systemInstance, volumes)
+	if err != nil {
+		return nil, err
+	}
+	return smis.startJob(res)
+}
+
+///////////////////////////////////////////////////////////
+//         START expanding a Storage Volume              //
+///////////////////////////////////////////////////////////
+
+func (smis *SMIS) StartExpandVolume(systemInstance *gowbem.InstanceName, volume *gowbem.InstanceName, newSizeBytes uint64) (*Job, error) {
+	return smis.StartExpandVolumeCtx(context.Background(), systemInstance, volume, newSizeBytes)
+}
+
+func (smis *SMIS) StartExpandVolumeCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume *gowbem.InstanceName, newSizeBytes uint64) (*Job, error) {
+	res, err := smis.invokeExpandVolumeCtx(ctx, systemInstance, volume, newSizeBytes)
+	if err != nil {
+		return nil, err
+	}
+	return smis.startJob(res)
+}
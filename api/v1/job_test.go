@@ -0,0 +1,217 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+func TestJobWaiterProgress(t *testing.T) {
+	var updates []JobProgress
+	smis.SetJobWaiter(JobWaiter{
+		InitialInterval: 250 * time.Millisecond,
+		MaxInterval:     2 * time.Second,
+		Multiplier:      2,
+		Timeout:         10 * time.Minute,
+		Progress: func(p JobProgress) {
+			fmt.Printf("job %s %d%% after %s\n", p.State, p.PercentComplete, p.Elapsed)
+			updates = append(updates, p)
+		},
+	})
+	defer smis.SetJobWaiter(DefaultJobWaiter)
+
+	PostVolRequest := &PostVolumesReq{
+		ElementName:        "govmax_test_waiter",
+		ElementType:        "2",
+		EMCNumberOfDevices: "1",
+		Size:               "123",
+	}
+	pools, _ := smis.GetStoragePools(testingInstance)
+	PostVolRequest.InPool = pools[0].InstancePath.InstanceName
+
+	volumes, err := smis.PostVolumes(PostVolRequest, testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	var volPaths []gowbem.InstancePath
+	for _, p := range volumes {
+		volPaths = append(volPaths, *p.InstancePath)
+	}
+	if err = smis.PostDeleteVol(testingInstance, volPaths); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+
+	if len(updates) == 0 {
+		t.Log("no progress reported")
+		t.Fail()
+	}
+}
+
+func TestStartPostVolumes(t *testing.T) {
+	pools, _ := smis.GetStoragePools(testingInstance)
+	var jobs []*Job
+	for i := 0; i < 2; i++ {
+		job, err := smis.StartPostVolumes(&PostVolumesReq{
+			ElementName:        fmt.Sprintf("govmax_test_async_%d", i),
+			ElementType:        "2",
+			EMCNumberOfDevices: "1",
+			Size:               "123",
+			InPool:             pools[0].InstancePath.InstanceName,
+		}, testingInstance)
+		if err != nil {
+			t.Log(err.Error())
+			t.Fail()
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	var volPaths []gowbem.InstancePath
+	for _, job := range jobs {
+		if status, err := job.Status(); err == nil {
+			fmt.Printf("job %s %d%%\n", status.State, status.PercentComplete)
+		}
+		volumes, err := job.Wait(context.Background())
+		if err != nil {
+			t.Log(err.Error())
+			t.Fail()
+			continue
+		}
+		for _, p := range volumes {
+			volPaths = append(volPaths, *p.InstancePath)
+		}
+	}
+
+	if len(volPaths) > 0 {
+		if err := smis.PostDeleteVol(testingInstance, volPaths); err != nil {
+			t.Log(err.Error())
+			t.Fail()
+		}
+	}
+}
+
+func TestCancelPostVolumes(t *testing.T) {
+	pools, _ := smis.GetStoragePools(testingInstance)
+	job, err := smis.StartPostVolumes(&PostVolumesReq{
+		ElementName:        "govmax_test_cancel",
+		ElementType:        "2",
+		EMCNumberOfDevices: "1",
+		Size:               "123",
+		InPool:             pools[0].InstancePath.InstanceName,
+	}, testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	if err = job.Cancel(); err != nil {
+		t.Log(err.Error())
+	}
+	volumes, err := job.Wait(context.Background())
+	fmt.Println("cancelled job:", err)
+
+	// the job may have finished before it could be terminated
+	var volPaths []gowbem.InstancePath
+	for _, p := range volumes {
+		volPaths = append(volPaths, *p.InstancePath)
+	}
+	if len(volPaths) > 0 {
+		if err := smis.PostDeleteVol(testingInstance, volPaths); err != nil {
+			t.Log(err.Error())
+			t.Fail()
+		}
+	}
+}
+
+// runningJobCIMOM serves a job that never leaves the RUNNING state and counts
+// the RequestStateChange calls made on it.
+func runningJobCIMOM(t *testing.T, terminated *int32) (*SMIS, func()) {
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("CIMMethod") == "RequestStateChange" {
+			atomic.AddInt32(terminated, 1)
+			w.Write([]byte(cimMessage(`<METHODRESPONSE NAME="RequestStateChange">` +
+				`<RETURNVALUE PARAMTYPE="uint32"><VALUE>0</VALUE></RETURNVALUE></METHODRESPONSE>`)))
+			return
+		}
+		w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="GetInstance"><IRETURNVALUE>` +
+			`<INSTANCE CLASSNAME="SE_ConcreteJob"><PROPERTY NAME="JobState" TYPE="uint16"><VALUE>4</VALUE></PROPERTY></INSTANCE>` +
+			`</IRETURNVALUE></IMETHODRESPONSE>`)))
+	})
+	return client, server.Close
+}
+
+// stalledJobCIMOM serves a job that stays in the given state.
+func stalledJobCIMOM(t *testing.T, jobState string) (*SMIS, func()) {
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="GetInstance"><IRETURNVALUE>` +
+			`<INSTANCE CLASSNAME="SE_ConcreteJob"><PROPERTY NAME="JobState" TYPE="uint16"><VALUE>` + jobState + `</VALUE></PROPERTY></INSTANCE>` +
+			`</IRETURNVALUE></IMETHODRESPONSE>`)))
+	})
+	return client, server.Close
+}
+
+var testJobPath = &gowbem.InstancePath{InstanceName: &gowbem.InstanceName{
+	ClassName:  "SE_ConcreteJob",
+	KeyBinding: []gowbem.KeyBinding{{Name: "InstanceID", KeyValue: &gowbem.KeyValue{"J1"}}},
+}}
+
+func TestJobWaiterTimeoutKeepsJob(t *testing.T) {
+	var terminated int32
+	client, closeServer := runningJobCIMOM(t, &terminated)
+	defer closeServer()
+
+	waiter := JobWaiter{InitialInterval: 10 * time.Millisecond, Timeout: 50 * time.Millisecond}
+	_, err := client.WaitForJobWithCtx(context.Background(), waiter, testJobPath, "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if atomic.LoadInt32(&terminated) != 0 {
+		t.Error("job terminated after the waiter's own timeout")
+	}
+}
+
+func TestJobWaiterCancelTerminatesJob(t *testing.T) {
+	var terminated int32
+	client, closeServer := runningJobCIMOM(t, &terminated)
+	defer closeServer()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	waiter := JobWaiter{InitialInterval: 10 * time.Millisecond}
+	_, err := client.WaitForJobWithCtx(ctx, waiter, testJobPath, "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context.DeadlineExceeded, got %v", err)
+	}
+	if atomic.LoadInt32(&terminated) != 1 {
+		t.Error("job not terminated after the caller's context was done")
+	}
+}
+
+func TestJobWaiterStalledJob(t *testing.T) {
+	client, closeServer := stalledJobCIMOM(t, strconv.Itoa(int(JobStateSuspended)))
+	defer closeServer()
+
+	_, err := client.WaitForJobWith(DefaultJobWaiter, testJobPath, "")
+	var jobErr *JobError
+	if !errors.As(err, &jobErr) || jobErr.State != "SUSPENDED" {
+		t.Fatalf("expected a SUSPENDED JobError, got %v", err)
+	}
+
+	waiter := JobWaiter{InitialInterval: 10 * time.Millisecond, Timeout: 50 * time.Millisecond, WaitOnStalledJobs: true}
+	_, err = client.WaitForJobWith(waiter, testJobPath, "")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected to wait on the suspended job until the timeout, got %v", err)
+	}
+}
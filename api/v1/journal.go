@@ -0,0 +1,290 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+///////////////////////////////////////////////////////////////
+//        Steps recorded in a Journal and their inverse      //
+///////////////////////////////////////////////////////////////
+
+type JournalStep string
+
+const (
+	StepCreateGroup       JournalStep = "CreateGroup"             // PostDeleteGroup
+	StepAddMembers        JournalStep = "AddMembersToGroup"       // RemoveMembersFromGroup
+	StepCreateMaskingView JournalStep = "CreateMaskingView"       // PostDeleteMaskingView
+	StepCreateHardwareID  JournalStep = "CreateStorageHardwareID" // DeleteStorageHardwareID
+	StepRemoveMembers     JournalStep = "RemoveMembersFromGroup"  // AddMembersToGroup
+	StepDeleteMaskingView JournalStep = "DeleteMaskingView"       // PostCreateMaskingView
+)
+
+// JournalEntry is one recorded step.  A deleted masking view keeps its Name
+// and its storage, initiator and port groups, in that order, in Members.
+type JournalEntry struct {
+	Step    JournalStep           `json:"Step"`
+	System  *gowbem.InstanceName  `json:"System"`
+	Target  *gowbem.InstancePath  `json:"Target"`
+	Name    string                `json:"Name,omitempty"`
+	Members []gowbem.InstancePath `json:"Members,omitempty"`
+}
+
+///////////////////////////////////////////////////////////////
+//     Journal of the steps of a multi-step array workflow   //
+//                                                           //
+//   Each step run through the journal is recorded with      //
+//   enough to undo it, once its array call has returned     //
+//   successfully; a step interrupted before that is not in  //
+//   the journal.  Rollback undoes them newest first;        //
+//   Commit forgets them once the workflow has succeeded.    //
+//   With a path the journal is saved after every change,    //
+//   so a process that crashed mid-workflow can reopen it    //
+//   and roll back on restart.                               //
+///////////////////////////////////////////////////////////////
+
+type Journal struct {
+	smis    *SMIS
+	path    string
+	lock    sync.Mutex
+	entries []JournalEntry
+}
+
+// NewJournal starts an empty journal, saved to path unless path is "".  A
+// journal left at path with steps in it is not overwritten: NewJournal
+// fails with ErrAlreadyExists, and the steps are kept for OpenJournal and
+// Rollback.
+func (smis *SMIS) NewJournal(path string) (*Journal, error) {
+	if path != "" {
+		existing, err := smis.OpenJournal(path)
+		if err != nil {
+			return nil, err
+		}
+		if len(existing.entries) > 0 {
+			return nil, fmt.Errorf("Journal %s holds %d step(s): %w", path, len(existing.entries), ErrAlreadyExists)
+		}
+	}
+	j := &Journal{smis: smis, path: path}
+	return j, j.save()
+}
+
+// OpenJournal loads the journal left at path by an earlier process.  A
+// missing file gives an empty journal.
+func (smis *SMIS) OpenJournal(path string) (*Journal, error) {
+	j := &Journal{smis: smis, path: path}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(data, &j.entries); err != nil {
+		return nil, err
+	}
+	return j, nil
+}
+
+// Entries returns a copy of the recorded steps, oldest first.
+func (j *Journal) Entries() []JournalEntry {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	return append([]JournalEntry(nil), j.entries...)
+}
+
+// save writes the entries to a temporary file and renames it over the
+// journal, so a crash never leaves a half-written journal behind.  The
+// lock must be held, or the journal not yet shared.
+func (j *Journal) save() error {
+	if j.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(j.entries)
+	if err != nil {
+		return err
+	}
+	tmp := j.path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, j.path)
+}
+
+// Record appends a step done outside the journal and saves it.
+func (j *Journal) Record(entry JournalEntry) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.entries = append(j.entries, entry)
+	return j.save()
+}
+
+// Commit forgets every step and removes the journal file.
+func (j *Journal) Commit() error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.entries = nil
+	if j.path == "" {
+		return nil
+	}
+	if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// journalRollbackTimeout bounds the rollback run by a failed workflow.  It
+// does not use the workflow's context, which may be why it failed.
+const journalRollbackTimeout = 10 * time.Minute
+
+// finish ends a workflow: it rolls back when err is set and returns err,
+// with the rollback error if some steps could not be undone, and otherwise
+// commits and returns the commit error.
+func (j *Journal) finish(err error) error {
+	if err == nil {
+		return j.Commit()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), journalRollbackTimeout)
+	defer cancel()
+	if rbErr := j.RollbackCtx(ctx); rbErr != nil {
+		return fmt.Errorf("%w (rollback: %v)", err, rbErr)
+	}
+	return err
+}
+
+///////////////////////////////////////////////////////////////
+//     ROLLBACK every recorded step, newest first            //
+///////////////////////////////////////////////////////////////
+
+// Rollback undoes the steps that can be undone and keeps the others in the
+// journal, so it can be retried.  The first error is returned.
+func (j *Journal) Rollback() error {
+	return j.RollbackCtx(context.Background())
+}
+
+func (j *Journal) RollbackCtx(ctx context.Context) error {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	var firstErr error
+	var failed []JournalEntry
+	for i := len(j.entries) - 1; i >= 0; i-- {
+		if err := j.undoCtx(ctx, &j.entries[i]); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			failed = append([]JournalEntry{j.entries[i]}, failed...)
+		}
+	}
+	j.entries = failed
+	if err := j.save(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+func (j *Journal) undoCtx(ctx context.Context, entry *JournalEntry) error {
+	smis := j.smis
+	switch entry.Step {
+	case StepCreateGroup:
+		return smis.PostDeleteGroupCtx(ctx, entry.System, entry.Target, true)
+	case StepAddMembers:
+		return smis.RemoveMembersFromGroupCtx(ctx, entry.System, entry.Target, entry.Members)
+	case StepCreateMaskingView:
+		return smis.PostDeleteMaskingViewCtx(ctx, entry.System, entry.Target)
+	case StepCreateHardwareID:
+		return smis.DeleteStorageHardwareIDCtx(ctx, entry.System, entry.Target)
+	case StepRemoveMembers:
+		return smis.AddMembersToGroupCtx(ctx, entry.System, entry.Target, entry.Members)
+	case StepDeleteMaskingView:
+		if len(entry.Members) != 3 {
+			return errors.New("Journal entry for masking view " + entry.Name + " has no groups")
+		}
+		_, err := smis.PostCreateMaskingViewCtx(ctx, entry.System, entry.Name, &entry.Members[0], &entry.Members[1], &entry.Members[2])
+		return err
+	}
+	return errors.New("Unknown journal step " + string(entry.Step))
+}
+
+///////////////////////////////////////////////////////////////
+//      Array operations that record themselves              //
+///////////////////////////////////////////////////////////////
+
+func (j *Journal) CreateGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, groupName string, groupType GroupType) (*gowbem.InstancePath, error) {
+	group, err := j.smis.CreateGroupCtx(ctx, systemInstance, groupName, groupType)
+	if err != nil {
+		return nil, err
+	}
+	return group, j.Record(JournalEntry{Step: StepCreateGroup, System: systemInstance, Target: group})
+}
+
+func (j *Journal) AddMembersToGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) error {
+	if err := j.smis.AddMembersToGroupCtx(ctx, systemInstance, group, members); err != nil {
+		return err
+	}
+	return j.Record(JournalEntry{Step: StepAddMembers, System: systemInstance, Target: group, Members: members})
+}
+
+func (j *Journal) CreateMaskingViewCtx(ctx context.Context, systemInstance *gowbem.InstanceName, mvName string, sg, ig, pg *gowbem.InstancePath) ([]gowbem.ObjectPath, error) {
+	created, err := j.smis.PostCreateMaskingViewCtx(ctx, systemInstance, mvName, sg, ig, pg)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range created {
+		if path.InstancePath != nil && path.InstancePath.InstanceName.ClassName == MaskingViewClass {
+			return created, j.Record(JournalEntry{Step: StepCreateMaskingView, System: systemInstance, Target: path.InstancePath})
+		}
+	}
+
+	// The job did not return the view, so look it up to record it.
+	mv, err := j.smis.findMaskingViewCtx(ctx, systemInstance, mvName)
+	if err != nil {
+		return created, err
+	}
+	return append(created, gowbem.ObjectPath{InstancePath: mv.InstancePath}),
+		j.Record(JournalEntry{Step: StepCreateMaskingView, System: systemInstance, Target: mv.InstancePath})
+}
+
+func (j *Journal) CreateStorageHardwareIDCtx(ctx context.Context, systemInstance *gowbem.InstanceName, storageID string, idType HardwareIDType) (*gowbem.InstancePath, error) {
+	id, err := j.smis.CreateStorageHardwareIDCtx(ctx, systemInstance, storageID, idType)
+	if err != nil {
+		return nil, err
+	}
+	return id, j.Record(JournalEntry{Step: StepCreateHardwareID, System: systemInstance, Target: id})
+}
+
+func (j *Journal) RemoveMembersFromGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstancePath, members []gowbem.InstancePath) error {
+	if err := j.smis.RemoveMembersFromGroupCtx(ctx, systemInstance, group, members); err != nil {
+		return err
+	}
+	return j.Record(JournalEntry{Step: StepRemoveMembers, System: systemInstance, Target: group, Members: members})
+}
+
+// DeleteMaskingViewCtx deletes a view and records its groups so that a
+// rollback can create it again.  The view must have all three groups.
+func (j *Journal) DeleteMaskingViewCtx(ctx context.Context, systemInstance *gowbem.InstanceName, mv *MaskingView) error {
+	if mv.StorageGroup == nil || mv.InitiatorGroup == nil || mv.PortGroup == nil {
+		return errors.New("Masking view " + mv.Name + " is missing a group and could not be restored")
+	}
+	if err := j.smis.PostDeleteMaskingViewCtx(ctx, systemInstance, mv.InstancePath); err != nil {
+		return err
+	}
+	return j.Record(JournalEntry{
+		Step:   StepDeleteMaskingView,
+		System: systemInstance,
+		Target: mv.InstancePath,
+		Name:   mv.Name,
+		Members: []gowbem.InstancePath{
+			*mv.StorageGroup.InstancePath,
+			*mv.InitiatorGroup.InstancePath,
+			*mv.PortGroup.InstancePath,
+		},
+	})
+}
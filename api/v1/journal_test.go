@@ -0,0 +1,150 @@
+package apiv1
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestJournalRollback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "govmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.json")
+
+	journal, err := smis.NewJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = journal.CreateGroupCtx(context.Background(), testingInstance, "govmax_test_journal_sg", GroupTypeStorage)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	// A restarted process finds the step in the saved journal.
+	reopened, err := smis.OpenJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reopened.Entries()) != 1 || reopened.Entries()[0].Step != StepCreateGroup {
+		t.Log("expected one CreateGroup entry, got", reopened.Entries())
+		t.Fail()
+	}
+
+	if err = reopened.Rollback(); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if len(reopened.Entries()) != 0 {
+		t.Log("entries left after rollback", reopened.Entries())
+		t.Fail()
+	}
+	_, err = smis.GetStorageGroupByName(testingInstance, "govmax_test_journal_sg")
+	if !errors.Is(err, ErrNotFound) {
+		t.Log("expected group to be deleted, got", err)
+		t.Fail()
+	}
+}
+
+func TestJournalCommit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "govmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.json")
+
+	journal, err := smis.NewJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = journal.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path); !os.IsNotExist(err) {
+		t.Log("journal file left after commit")
+		t.Fail()
+	}
+}
+
+func TestJournalDeleteMaskingViewNeedsGroups(t *testing.T) {
+	journal, err := new(SMIS).NewJournal("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	mv := &MaskingView{Name: "govmax_test_MV", StorageGroup: &StorageGroup{}}
+	if err = journal.DeleteMaskingViewCtx(context.Background(), nil, mv); err == nil {
+		t.Log("a view that could not be restored was deleted")
+		t.Fail()
+	}
+	if len(journal.Entries()) != 0 {
+		t.Log("unexpected entries", journal.Entries())
+		t.Fail()
+	}
+}
+
+func TestNewJournalKeepsExistingSteps(t *testing.T) {
+	dir, err := ioutil.TempDir("", "govmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "journal.json")
+
+	journal, err := new(SMIS).NewJournal(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = journal.Record(JournalEntry{Step: StepCreateGroup}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = new(SMIS).NewJournal(path); !errors.Is(err, ErrAlreadyExists) {
+		t.Log("expected ErrAlreadyExists, got", err)
+		t.Fail()
+	}
+	reopened, err := new(SMIS).OpenJournal(path)
+	if err != nil || len(reopened.Entries()) != 1 {
+		t.Log("existing steps were lost", err)
+		t.Fail()
+	}
+
+	if err = journal.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = new(SMIS).NewJournal(path); err != nil {
+		t.Log("committed journal not reusable", err)
+		t.Fail()
+	}
+}
+
+func TestJournalFinishReportsRollbackError(t *testing.T) {
+	journal, err := new(SMIS).NewJournal("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = journal.Record(JournalEntry{Step: "NoSuchStep"}); err != nil {
+		t.Fatal(err)
+	}
+
+	workflowErr := errors.New("add failed")
+	err = journal.finish(workflowErr)
+	if !errors.Is(err, workflowErr) || !strings.Contains(err.Error(), "rollback: Unknown journal step NoSuchStep") {
+		t.Log("expected the workflow and rollback errors, got", err)
+		t.Fail()
+	}
+	if len(journal.Entries()) != 1 {
+		t.Log("the step that could not be undone was dropped", journal.Entries())
+		t.Fail()
+	}
+}
@@ -0,0 +1,337 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+////////////////////////////////////////////////////////////////
+//     A volume mapped through a masking view and its HLU     //
+////////////////////////////////////////////////////////////////
+
+type LunMapping struct {
+	Volume   *gowbem.InstanceName
+	DeviceID string
+	HLU      int
+}
+
+////////////////////////////////////////////////////////////////
+//      Struct used to store a decoded Masking View and the   //
+//          groups it is made of                              //
+////////////////////////////////////////////////////////////////
+
+type MaskingView struct {
+	InstancePath   *gowbem.InstancePath
+	Name           string
+	DeviceID       string
+	StorageGroup   *StorageGroup
+	InitiatorGroup *InitiatorGroup
+	PortGroup      *PortGroup
+	Luns           []LunMapping
+}
+
+// HLU returns the host LUN of a volume in the view.
+func (mv *MaskingView) HLU(deviceID string) (int, bool) {
+	for _, lun := range mv.Luns {
+		if lun.DeviceID == deviceID {
+			return lun.HLU, true
+		}
+	}
+	return -1, false
+}
+
+// ParseHLU reads a DeviceNumber of CIM_ProtocolControllerForUnit, which
+// the VMAX reports in hex.
+func ParseHLU(deviceNumber string) (int, error) {
+	hlu, err := strconv.ParseInt(strings.TrimPrefix(strings.ToLower(deviceNumber), "0x"), 16, 32)
+	return int(hlu), err
+}
+
+// lunMapping decodes one CIM_ProtocolControllerForUnit of a masking view.
+func lunMapping(unit *Association) (*LunMapping, error) {
+	volume, err := unit.Reference("Dependent")
+	if err != nil {
+		return nil, err
+	}
+	deviceID, err := GetKeyFromInstanceName(volume, "DeviceID")
+	if err != nil {
+		return nil, err
+	}
+	hlu, err := ParseHLU(unit.Property("DeviceNumber"))
+	if err != nil {
+		return nil, fmt.Errorf("HLU of volume %s: %v", deviceID, err)
+	}
+	return &LunMapping{
+		Volume:   volume,
+		DeviceID: deviceID.(string),
+		HLU:      hlu,
+	}, nil
+}
+
+///////////////////////////////////////////////////////////////
+//      GET the LUN mappings of a Masking View               //
+//                                                           //
+//   A mapping that cannot be decoded is left out and        //
+//   reported in a *PartialError returned with the rest.     //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetMaskingViewLuns(maskingView *gowbem.InstanceName) ([]LunMapping, error) {
+	return smis.GetMaskingViewLunsCtx(context.Background(), maskingView)
+}
+
+func (smis *SMIS) GetMaskingViewLunsCtx(ctx context.Context, maskingView *gowbem.InstanceName) ([]LunMapping, error) {
+	units, err := smis.ReferencesCtx(ctx, maskingView, "CIM_ProtocolControllerForUnit", "Antecedent")
+	if err != nil {
+		return nil, err
+	}
+
+	var luns []LunMapping
+	var errs []error
+	for i := range units {
+		lun, err := lunMapping(&units[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		luns = append(luns, *lun)
+	}
+	return luns, partialError("GetMaskingViewLuns", errs)
+}
+
+///////////////////////////////////////////////////////////////
+//      Index of the groups of every masking view, used to   //
+//      list view details in a few calls                     //
+///////////////////////////////////////////////////////////////
+
+// Each kind of masking group is tied to its masking views by its own
+// association, with the group as Antecedent and the view as Dependent.
+var maskingGroupAssociations = []string{
+	"CIM_AssociatedDeviceMaskingGroup",
+	"CIM_AssociatedInitiatorMaskingGroup",
+	"CIM_AssociatedTargetMaskingGroup",
+}
+
+var maskingViewProperties = []string{"ElementName", "DeviceID"}
+
+type maskingViewIndex struct {
+	groups     *groupIndex
+	byKey      map[string]*gowbem.ValueObjectWithPath
+	viewGroups map[string][]string
+}
+
+// maskingViewIndexCtx reads the members of the given groups and the views
+// of every group with one enumeration of each association class.
+func (smis *SMIS) maskingViewIndexCtx(ctx context.Context, groups []gowbem.ValueObjectWithPath) (*maskingViewIndex, error) {
+	memberships, err := smis.EnumerateAssociationsCtx(ctx, memberOfCollection)
+	if err != nil {
+		return nil, err
+	}
+	index := &maskingViewIndex{
+		groups:     newGroupIndex(groups, memberships),
+		byKey:      make(map[string]*gowbem.ValueObjectWithPath),
+		viewGroups: make(map[string][]string),
+	}
+	for i := range groups {
+		index.byKey[instanceNameKey(groups[i].InstancePath.InstanceName)] = &groups[i]
+	}
+
+	for _, assocClass := range maskingGroupAssociations {
+		associations, err := smis.EnumerateAssociationsCtx(ctx, assocClass)
+		if err != nil {
+			return nil, err
+		}
+		for i := range associations {
+			group, err := associations[i].Reference("Antecedent")
+			if err != nil {
+				continue
+			}
+			view, err := associations[i].Reference("Dependent")
+			if err != nil || view.ClassName != MaskingViewClass {
+				continue
+			}
+			groupKey, viewKey := instanceNameKey(group), instanceNameKey(view)
+			if index.byKey[groupKey] == nil {
+				continue
+			}
+			index.groups.views[groupKey] = append(index.groups.views[groupKey], gowbem.InstancePath{InstanceName: view})
+			index.viewGroups[viewKey] = append(index.viewGroups[viewKey], groupKey)
+		}
+	}
+	return index, nil
+}
+
+func (index *maskingViewIndex) maskingView(view *gowbem.ValueObjectWithPath) *MaskingView {
+	mv := &MaskingView{
+		InstancePath: view.InstancePath,
+		Name:         propertyString(view.Instance, "ElementName"),
+		DeviceID:     propertyString(view.Instance, "DeviceID"),
+	}
+	for _, groupKey := range index.viewGroups[instanceNameKey(view.InstancePath.InstanceName)] {
+		group := index.byKey[groupKey]
+		switch group.InstancePath.InstanceName.ClassName {
+		case StorageGroupClass:
+			sg := index.groups.storageGroup(group)
+			mv.StorageGroup = &sg
+		case InitiatorGroupClass:
+			ig := index.groups.initiatorGroup(group)
+			mv.InitiatorGroup = &ig
+		case PortGroupClass:
+			pg := index.groups.portGroup(group)
+			mv.PortGroup = &pg
+		}
+	}
+	return mv
+}
+
+///////////////////////////////////////////////////////////////
+//      GET a Masking View with its groups and LUNs          //
+//                                                           //
+//   LUN mappings that cannot be decoded are reported in a   //
+//   *PartialError returned with the view.                   //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetMaskingViewDetails(maskingView *gowbem.InstancePath) (*MaskingView, error) {
+	return smis.GetMaskingViewDetailsCtx(context.Background(), maskingView)
+}
+
+func (smis *SMIS) GetMaskingViewDetailsCtx(ctx context.Context, maskingView *gowbem.InstancePath) (*MaskingView, error) {
+	name := maskingView.InstanceName
+	instance, err := smis.GetInstanceCtx(ctx, name, false, maskingViewProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []gowbem.ValueObjectWithPath
+	for _, className := range []string{StorageGroupClass, InitiatorGroupClass, PortGroupClass} {
+		viewGroups, err := smis.AssociatorInstancesCtx(ctx, name, "", className, nil, nil, false, groupProperties)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, viewGroups...)
+	}
+	index, err := smis.maskingViewIndexCtx(ctx, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	mv := index.maskingView(&gowbem.ValueObjectWithPath{InstancePath: maskingView, Instance: instance})
+	mv.Luns, err = smis.GetMaskingViewLunsCtx(ctx, name)
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+	return mv, err
+}
+
+///////////////////////////////////////////////////////////////
+//      GET the details of every Masking View                //
+//                                                           //
+//   The groups and LUNs of all views are read with one      //
+//   enumeration of each association class.  LUN mappings    //
+//   that cannot be decoded are reported in a *PartialError  //
+//   returned with the views.                                //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListMaskingViewDetails(systemInstance *gowbem.InstanceName) ([]MaskingView, error) {
+	return smis.ListMaskingViewDetailsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) ListMaskingViewDetailsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]MaskingView, error) {
+	views, err := smis.AssociatorInstancesCtx(ctx, systemInstance, "", MaskingViewClass, nil, nil, false, maskingViewProperties)
+	if err != nil {
+		return nil, err
+	}
+
+	var groups []gowbem.ValueObjectWithPath
+	for _, className := range []string{StorageGroupClass, InitiatorGroupClass, PortGroupClass} {
+		classGroups, err := smis.listGroupsCtx(ctx, systemInstance, className)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, classGroups...)
+	}
+	index, err := smis.maskingViewIndexCtx(ctx, groups)
+	if err != nil {
+		return nil, err
+	}
+
+	luns, errs, err := smis.lunIndexCtx(ctx, views)
+	if err != nil {
+		return nil, err
+	}
+
+	var details []MaskingView
+	for i := range views {
+		mv := index.maskingView(&views[i])
+		mv.Luns = luns[instanceNameKey(views[i].InstancePath.InstanceName)]
+		details = append(details, *mv)
+	}
+	return details, partialError("ListMaskingViewDetails", errs)
+}
+
+// lunIndexCtx reads the LUN mappings of the given views, keyed by view,
+// with one enumeration of CIM_ProtocolControllerForUnit.
+func (smis *SMIS) lunIndexCtx(ctx context.Context, views []gowbem.ValueObjectWithPath) (map[string][]LunMapping, []error, error) {
+	known := make(map[string]bool)
+	for _, view := range views {
+		known[instanceNameKey(view.InstancePath.InstanceName)] = true
+	}
+	units, err := smis.EnumerateAssociationsCtx(ctx, "CIM_ProtocolControllerForUnit")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	luns := make(map[string][]LunMapping)
+	var errs []error
+	for i := range units {
+		view, err := units[i].Reference("Antecedent")
+		if err != nil || !known[instanceNameKey(view)] {
+			continue
+		}
+		lun, err := lunMapping(&units[i])
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		key := instanceNameKey(view)
+		luns[key] = append(luns[key], *lun)
+	}
+	return luns, errs, nil
+}
+
+///////////////////////////////////////////////////////////////
+//            GET a Masking View by name                     //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetMaskingViewByName(systemInstance *gowbem.InstanceName, name string) (*MaskingView, error) {
+	return smis.GetMaskingViewByNameCtx(context.Background(), systemInstance, name)
+}
+
+func (smis *SMIS) GetMaskingViewByNameCtx(ctx context.Context, systemInstance *gowbem.InstanceName, name string) (*MaskingView, error) {
+	views, err := smis.AssociatorInstancesCtx(ctx, systemInstance, "", MaskingViewClass, nil, nil, false, []string{"ElementName"})
+	if err != nil {
+		return nil, err
+	}
+	for _, view := range views {
+		if propertyString(view.Instance, "ElementName") == name {
+			return smis.GetMaskingViewDetailsCtx(ctx, view.InstancePath)
+		}
+	}
+	return nil, fmt.Errorf("%s %s %w", MaskingViewClass, name, ErrNotFound)
+}
+
+// findMaskingViewCtx is GetMaskingViewByNameCtx for the export workflows,
+// which do not use the LUN mappings and so ignore those that cannot be read.
+func (smis *SMIS) findMaskingViewCtx(ctx context.Context, systemInstance *gowbem.InstanceName, name string) (*MaskingView, error) {
+	mv, err := smis.GetMaskingViewByNameCtx(ctx, systemInstance, name)
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		return mv, nil
+	}
+	return mv, err
+}
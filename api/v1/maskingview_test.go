@@ -0,0 +1,173 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"testing"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+)
+
+func TestParseHLU(t *testing.T) {
+	for value, expected := range map[string]int{"0001": 1, "00FF": 255, "0x10": 16} {
+		hlu, err := ParseHLU(value)
+		if err != nil || hlu != expected {
+			t.Logf("ParseHLU(%s) = %d, %v", value, hlu, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestListMaskingViewDetails(t *testing.T) {
+	views, err := smis.ListMaskingViewDetails(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	for _, mv := range views {
+		fmt.Printf("view=%s luns=%d\n", mv.Name, len(mv.Luns))
+		if mv.StorageGroup == nil || mv.InitiatorGroup == nil || mv.PortGroup == nil {
+			t.Log("incomplete masking view " + mv.Name)
+			t.Fail()
+			continue
+		}
+		for _, lun := range mv.Luns {
+			fmt.Printf("  volume=%s hlu=%d initiators=%d\n", lun.DeviceID, lun.HLU, len(mv.InitiatorGroup.Initiators))
+		}
+	}
+}
+
+func testInstanceXML(className, key, value string) string {
+	return `<INSTANCENAME CLASSNAME="` + className + `"><KEYBINDING NAME="` + key + `"><KEYVALUE>` + value + `</KEYVALUE></KEYBINDING></INSTANCENAME>`
+}
+
+// testUnit is the path and instance of a CIM_ProtocolControllerForUnit of
+// the host01_MV view.
+func testUnit(deviceID, deviceNumber string) string {
+	return `<INSTANCEPATH><NAMESPACEPATH/>` + testInstanceXML("Symm_ProtocolControllerForUnit", "DeviceNumber", deviceNumber) + `</INSTANCEPATH>` +
+		`<INSTANCE CLASSNAME="Symm_ProtocolControllerForUnit">` +
+		`<PROPERTY.REFERENCE NAME="Antecedent"><VALUE.REFERENCE>` + testInstanceXML(MaskingViewClass, "DeviceID", "host01_MV") + `</VALUE.REFERENCE></PROPERTY.REFERENCE>` +
+		`<PROPERTY.REFERENCE NAME="Dependent"><VALUE.REFERENCE>` + testInstanceXML("Symm_StorageVolume", "DeviceID", deviceID) + `</VALUE.REFERENCE></PROPERTY.REFERENCE>` +
+		`<PROPERTY NAME="DeviceNumber" TYPE="string"><VALUE>` + deviceNumber + `</VALUE></PROPERTY></INSTANCE>`
+}
+
+// testViewGroup ties a group of the host01_MV view to the view.
+func testViewGroup(className, instanceID string) string {
+	return `<VALUE.INSTANCEWITHPATH><INSTANCEPATH><NAMESPACEPATH/>` + testInstanceXML("CIM_Dependency", "InstanceID", instanceID) + `</INSTANCEPATH>` +
+		`<INSTANCE CLASSNAME="CIM_Dependency">` +
+		`<PROPERTY.REFERENCE NAME="Antecedent"><VALUE.REFERENCE>` + testInstanceXML(className, "InstanceID", instanceID) + `</VALUE.REFERENCE></PROPERTY.REFERENCE>` +
+		`<PROPERTY.REFERENCE NAME="Dependent"><VALUE.REFERENCE>` + testInstanceXML(MaskingViewClass, "DeviceID", "host01_MV") + `</VALUE.REFERENCE></PROPERTY.REFERENCE>` +
+		`</INSTANCE></VALUE.INSTANCEWITHPATH>`
+}
+
+var testClassName = regexp.MustCompile(`<CLASSNAME NAME="([^"]+)"/>`)
+
+// maskingViewCIMOM serves one masking view with a group of each kind and
+// two LUN mappings, the second with an unreadable HLU.
+func maskingViewCIMOM(t *testing.T, methods *[]string) (*SMIS, func()) {
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		method := r.Header.Get("CIMMethod")
+		*methods = append(*methods, method)
+		className := ""
+		if match := testClassName.FindSubmatch(body); match != nil {
+			className = string(match[1])
+		}
+
+		var values string
+		switch {
+		case method == "AssociatorNames" && className == "EMC_ControllerConfigurationService":
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="AssociatorNames"><IRETURNVALUE><OBJECTPATH><INSTANCEPATH><NAMESPACEPATH/>` +
+				testInstanceXML(className, "Name", "EMCControllerConfigurationService") + `</INSTANCEPATH></OBJECTPATH></IRETURNVALUE></IMETHODRESPONSE>`)))
+			return
+		case method == "Associators":
+			key, value := "InstanceID", map[string]string{StorageGroupClass: "host01_SG", InitiatorGroupClass: "host01_IG", PortGroupClass: "host01_PG"}[className]
+			if className == MaskingViewClass {
+				key, value = "DeviceID", "host01_MV"
+			}
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="Associators"><IRETURNVALUE><VALUE.OBJECTWITHPATH><INSTANCEPATH><NAMESPACEPATH/>` +
+				testInstanceXML(className, key, value) + `</INSTANCEPATH><INSTANCE CLASSNAME="` + className + `">` +
+				`<PROPERTY NAME="ElementName" TYPE="string"><VALUE>` + value + `</VALUE></PROPERTY></INSTANCE>` +
+				`</VALUE.OBJECTWITHPATH></IRETURNVALUE></IMETHODRESPONSE>`)))
+			return
+		case method == "OpenEnumerateInstances" && className == "CIM_ProtocolControllerForUnit":
+			values = `<VALUE.INSTANCEWITHPATH>` + testUnit("00ABC", "0001") + `</VALUE.INSTANCEWITHPATH>` +
+				`<VALUE.INSTANCEWITHPATH>` + testUnit("00ABD", "zz") + `</VALUE.INSTANCEWITHPATH>`
+		case method == "OpenEnumerateInstances" && className == "CIM_AssociatedDeviceMaskingGroup":
+			values = testViewGroup(StorageGroupClass, "host01_SG")
+		case method == "OpenEnumerateInstances" && className == "CIM_AssociatedInitiatorMaskingGroup":
+			values = testViewGroup(InitiatorGroupClass, "host01_IG")
+		case method == "OpenEnumerateInstances" && className == "CIM_AssociatedTargetMaskingGroup":
+			values = testViewGroup(PortGroupClass, "host01_PG")
+		case method == "OpenEnumerateInstances":
+		default:
+			t.Errorf("unexpected %s of %s", method, className)
+		}
+		w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="` + method + `"><IRETURNVALUE>` + values + `</IRETURNVALUE>` +
+			`<PARAMVALUE NAME="EndOfSequence"><VALUE>TRUE</VALUE></PARAMVALUE></IMETHODRESPONSE>`)))
+	})
+	return client, server.Close
+}
+
+func TestListMaskingViewDetailsIndexed(t *testing.T) {
+	var methods []string
+	client, closeServer := maskingViewCIMOM(t, &methods)
+	defer closeServer()
+
+	views, err := client.ListMaskingViewDetails(testVolumeName)
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(partial.Errors) != 1 {
+		t.Errorf("expected the unreadable HLU in a PartialError, got %v", err)
+	}
+	if len(views) != 1 {
+		t.Fatalf("expected one view, got %d", len(views))
+	}
+	mv := views[0]
+	if mv.Name != "host01_MV" || mv.StorageGroup == nil || mv.InitiatorGroup == nil || mv.PortGroup == nil {
+		t.Fatalf("incomplete masking view %+v", mv)
+	}
+	if len(mv.PortGroup.MaskingViews) != 1 {
+		t.Errorf("port group views not indexed: %+v", mv.PortGroup.MaskingViews)
+	}
+	if hlu, ok := mv.HLU("00ABC"); !ok || hlu != 1 {
+		t.Errorf("expected HLU 1 for 00ABC, got %d %v", hlu, ok)
+	}
+	// one controller lookup per group class, and nothing per view
+	lookups := 0
+	for _, method := range methods {
+		switch method {
+		case "AssociatorNames":
+			lookups++
+		case "GetInstance", "References":
+			t.Errorf("masking view resolved with per-view calls: %v", methods)
+		}
+	}
+	if lookups != 3 {
+		t.Errorf("masking view resolved with per-view calls: %v", methods)
+	}
+}
+
+func TestGetMaskingViewLunsPartial(t *testing.T) {
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="References"><IRETURNVALUE>` +
+			`<VALUE.OBJECTWITHPATH>` + testUnit("00ABC", "0001") + `</VALUE.OBJECTWITHPATH>` +
+			`<VALUE.OBJECTWITHPATH>` + testUnit("00ABD", "zz") + `</VALUE.OBJECTWITHPATH>` +
+			`</IRETURNVALUE></IMETHODRESPONSE>`)))
+	})
+	defer server.Close()
+
+	view := &gowbem.InstanceName{ClassName: MaskingViewClass, KeyBinding: []gowbem.KeyBinding{{Name: "DeviceID", KeyValue: &gowbem.KeyValue{"host01_MV"}}}}
+	luns, err := client.GetMaskingViewLuns(view)
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(partial.Errors) != 1 {
+		t.Errorf("expected the unreadable HLU in a PartialError, got %v", err)
+	}
+	if len(luns) != 1 || luns[0].DeviceID != "00ABC" {
+		t.Errorf("expected the readable mapping only, got %+v", luns)
+	}
+}
@@ -0,0 +1,190 @@
+package apiv1
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+// Keys of VMAX instances join their parts with this separator, e.g. the
+// SystemName SYMMETRIX-+-000196701380-+-FA-1D-+-4 of a front end endpoint.
+const keySeparator = "-+-"
+
+////////////////////////////////////////////////////////////////
+//     A front end port: its director, port number and the    //
+//          WWPN or IQN hosts log in to                       //
+////////////////////////////////////////////////////////////////
+
+type FrontEndPort struct {
+	InstancePath *gowbem.InstancePath
+	Director     string
+	PortNumber   string
+	WWPN         string
+	IQN          string
+	Protocol     FrontEndProtocol
+	Status       PortStatus
+	Speed        uint64
+}
+
+// Address returns the WWPN of an FC port or the IQN of an iSCSI port.
+func (p *FrontEndPort) Address() string {
+	if p.Protocol == FrontEndProtocolISCSI {
+		return p.IQN
+	}
+	return p.WWPN
+}
+
+// ParseFrontEndPortName splits the SystemName of a front end endpoint into
+// its director and port number.  Both the SYMMETRIX-+-<sid>-+-FA-1D-+-4
+// form and the older SYMMETRIX-+-<sid>-+-4-FA-1D form are understood; the
+// port number is "" when the name does not carry one.
+func ParseFrontEndPortName(systemName string) (director, port string, err error) {
+	parts := strings.Split(systemName, keySeparator)
+	if len(parts) < 3 || parts[2] == "" {
+		return "", "", errors.New("Invalid front end port name " + systemName)
+	}
+
+	director = parts[2]
+	if len(parts) > 3 {
+		port = parts[3]
+	} else if i := strings.Index(director, "-"); i > 0 && isDigits(director[:i]) {
+		port, director = director[:i], director[i+1:]
+	}
+	if director == "" || (port != "" && !isDigits(port)) {
+		return "", "", errors.New("Invalid front end port name " + systemName)
+	}
+	return director, port, nil
+}
+
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+var frontEndPortProperties = []string{"Name", "ConnectionType", "EnabledState"}
+
+// parseFrontEndPort builds a FrontEndPort from an endpoint instance.
+func parseFrontEndPort(path *gowbem.InstancePath, instance *gowbem.Instance) (*FrontEndPort, error) {
+	systemName, err := keyString(path.InstanceName, "SystemName")
+	if err != nil {
+		return nil, err
+	}
+	director, port, err := ParseFrontEndPortName(systemName)
+	if err != nil {
+		return nil, err
+	}
+
+	name := propertyString(instance, "Name")
+	if name == "" {
+		if key, err := keyString(path.InstanceName, "Name"); err == nil {
+			name = key
+		}
+	}
+
+	fep := &FrontEndPort{
+		InstancePath: path,
+		Director:     director,
+		PortNumber:   port,
+		Protocol:     FrontEndProtocol(propertyUint64(instance, "ConnectionType")),
+		Status:       PortStatus(propertyUint64(instance, "EnabledState")),
+	}
+	if fep.Protocol.Validate() != nil {
+		fep.Protocol = FrontEndProtocolFC
+		if strings.HasPrefix(strings.ToLower(name), "iqn.") {
+			fep.Protocol = FrontEndProtocolISCSI
+		}
+	}
+	if fep.Protocol == FrontEndProtocolISCSI {
+		fep.IQN = name
+	} else {
+		fep.WWPN = name
+	}
+	return fep, nil
+}
+
+///////////////////////////////////////////////////////////////
+//        GET the details of every Front End Port            //
+//                                                           //
+//   Ports that cannot be decoded are left out and reported  //
+//   in a *PartialError returned with the rest.              //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListFrontEndPorts(systemInstance *gowbem.InstanceName) ([]FrontEndPort, error) {
+	return smis.ListFrontEndPortsCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) ListFrontEndPortsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]FrontEndPort, error) {
+	endpoints, err := smis.GetTargetEndpointsCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	var ports []FrontEndPort
+	var errs []error
+	for _, endpoint := range endpoints {
+		if endpoint.InstancePath == nil {
+			continue
+		}
+		name := endpoint.InstancePath.InstanceName
+		instance, err := smis.GetInstanceCtx(ctx, name, false, frontEndPortProperties)
+		if err != nil {
+			return nil, err
+		}
+		port, err := parseFrontEndPort(endpoint.InstancePath, instance)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		// Speed is reported by the physical port behind the endpoint.
+		logical, err := smis.AssociatorInstancesCtx(ctx, name, "CIM_DeviceSAPImplementation", "CIM_LogicalPort", nil, nil, false, []string{"Speed"})
+		if err != nil {
+			return nil, err
+		}
+		if len(logical) > 0 {
+			port.Speed = propertyUint64(logical[0].Instance, "Speed")
+		}
+		ports = append(ports, *port)
+	}
+
+	// iSCSI targets sit on directors GetTargetEndpoints does not return.
+	targets, err := smis.ListISCSITargetsCtx(ctx, systemInstance)
+	var partial *PartialError
+	if errors.As(err, &partial) {
+		errs = append(errs, partial.Errors...)
+	} else if err != nil {
+		return nil, err
+	}
+	for _, target := range targets {
+		if containsFrontEndPort(ports, target.InstancePath) {
+			continue
+		}
+		ports = append(ports, FrontEndPort{
+			InstancePath: target.InstancePath,
+			Director:     target.Director,
+			PortNumber:   target.PortNumber,
+			IQN:          target.IQN,
+			Protocol:     FrontEndProtocolISCSI,
+			Status:       target.Status,
+		})
+	}
+	return ports, partialError("ListFrontEndPorts", errs)
+}
+
+func containsFrontEndPort(ports []FrontEndPort, path *gowbem.InstancePath) bool {
+	for idx := range ports {
+		if sameInstanceName(ports[idx].InstancePath.InstanceName, path.InstanceName) {
+			return true
+		}
+	}
+	return false
+}
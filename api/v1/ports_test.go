@@ -0,0 +1,65 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+)
+
+func TestParseFrontEndPortName(t *testing.T) {
+	tests := []struct {
+		systemName string
+		director   string
+		port       string
+		valid      bool
+	}{
+		{"SYMMETRIX-+-000196701380-+-FA-1D-+-4", "FA-1D", "4", true},
+		{"SYMMETRIX-+-000196701380-+-4-FA-1D", "FA-1D", "4", true},
+		{"SYMMETRIX-+-000196701380-+-SE-2G", "SE-2G", "", true},
+		{"SYMMETRIX-+-000196701380-+-FA-1D-+-x", "", "", false},
+		{"SYMMETRIX-+-000196701380", "", "", false},
+		{"FA-1D", "", "", false},
+		{"", "", "", false},
+	}
+	for _, test := range tests {
+		director, port, err := ParseFrontEndPortName(test.systemName)
+		if (err == nil) != test.valid || director != test.director || port != test.port {
+			t.Log("unexpected parse of", test.systemName, director, port, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestListFrontEndPorts(t *testing.T) {
+	ports, err := smis.ListFrontEndPorts(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	for _, port := range ports {
+		fmt.Printf("%s:%s %s %s %s speed=%d\n", port.Director, port.PortNumber, port.Protocol, port.Address(), port.Status, port.Speed)
+		if port.Director == "" || port.Address() == "" {
+			t.Log("incomplete front end port", port)
+			t.Fail()
+		}
+	}
+}
+
+func TestParseFrontEndPortMalformed(t *testing.T) {
+	path := &gowbem.InstancePath{InstanceName: &gowbem.InstanceName{
+		ClassName:  "Symm_FCSCSIProtocolEndpoint",
+		KeyBinding: []gowbem.KeyBinding{{Name: "SystemName", KeyValue: &gowbem.KeyValue{"SYMMETRIX-+-000196701380"}}},
+	}}
+	if _, err := parseFrontEndPort(path, &gowbem.Instance{}); err == nil {
+		t.Log("malformed SystemName accepted")
+		t.Fail()
+	}
+	path.InstanceName.KeyBinding = nil
+	if _, err := parseFrontEndPort(path, &gowbem.Instance{}); !errors.Is(err, ErrNotFound) {
+		t.Log("expected ErrNotFound without a SystemName, got", err)
+		t.Fail()
+	}
+}
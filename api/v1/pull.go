@@ -0,0 +1,393 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+// DefaultMaxObjectCount is the page size used by the Open and Pull
+// operations when the caller passes a MaxObjectCount of zero or less.
+const DefaultMaxObjectCount = 500
+
+///////////////////////////////////////////////////////////////
+//      Result of an Open or Pull enumeration operation      //
+///////////////////////////////////////////////////////////////
+
+type PullResult struct {
+	Instances          []InstanceWithPath
+	EnumerationContext string
+	EndOfSequence      bool
+}
+
+func decodePullResult(resp *cimIMethodResponse) (*PullResult, error) {
+	var ret struct {
+		Instances []InstanceWithPath `xml:"VALUE.INSTANCEWITHPATH"`
+	}
+	enumerationContext, eos, err := decodePullPage(resp, &ret)
+	if err != nil {
+		return nil, err
+	}
+	return &PullResult{
+		Instances:          ret.Instances,
+		EnumerationContext: enumerationContext,
+		EndOfSequence:      eos,
+	}, nil
+}
+
+// decodePullPage decodes the instances of an Open or Pull response into v
+// and returns where the enumeration stands.
+func decodePullPage(resp *cimIMethodResponse, v interface{}) (string, bool, error) {
+	eos, err := strconv.ParseBool(resp.paramValue("EndOfSequence"))
+	if err != nil {
+		return "", false, errors.New(resp.Name + ": EndOfSequence not found")
+	}
+	if err = resp.decodeReturn(v); err != nil {
+		return "", false, err
+	}
+	enumerationContext := resp.paramValue("EnumerationContext")
+	if !eos && enumerationContext == "" {
+		return "", false, errors.New(resp.Name + ": EnumerationContext not found")
+	}
+	return enumerationContext, eos, nil
+}
+
+func maxObjectCountParam(maxObjectCount int) cimIParam {
+	if maxObjectCount <= 0 {
+		maxObjectCount = DefaultMaxObjectCount
+	}
+	return cimIParam{Name: "MaxObjectCount", XML: xmlValue(strconv.Itoa(maxObjectCount))}
+}
+
+////////////////////////////
+// OpenEnumerateInstances //
+////////////////////////////
+
+func (smis *SMIS) OpenEnumerateInstances(className string, propertyList []string, maxObjectCount int) (*PullResult, error) {
+	return smis.OpenEnumerateInstancesCtx(context.Background(), className, propertyList, maxObjectCount)
+}
+
+func (smis *SMIS) OpenEnumerateInstancesCtx(ctx context.Context, className string, propertyList []string, maxObjectCount int) (*PullResult, error) {
+	params := []cimIParam{{Name: "ClassName", XML: xmlClassName(className)}}
+	if propertyList != nil {
+		params = append(params, cimIParam{Name: "PropertyList", XML: xmlValueArray(propertyList)})
+	}
+	params = append(params, maxObjectCountParam(maxObjectCount))
+
+	resp, err := smis.invokeIntrinsicCtx(ctx, "OpenEnumerateInstances", params)
+	if err != nil {
+		return nil, err
+	}
+	return decodePullResult(resp)
+}
+
+/////////////////////////////
+// OpenAssociatorInstances //
+/////////////////////////////
+
+func (smis *SMIS) OpenAssociatorInstances(instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string, propertyList []string, maxObjectCount int) (*PullResult, error) {
+	return smis.OpenAssociatorInstancesCtx(context.Background(), instanceName, assocClass, resultClass, role, resultRole, propertyList, maxObjectCount)
+}
+
+func (smis *SMIS) OpenAssociatorInstancesCtx(ctx context.Context, instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string, propertyList []string, maxObjectCount int) (*PullResult, error) {
+	params := []cimIParam{{Name: "InstanceName", XML: xmlInstanceName(instanceName)}}
+	if assocClass != "" {
+		params = append(params, cimIParam{Name: "AssocClass", XML: xmlClassName(assocClass)})
+	}
+	if resultClass != "" {
+		params = append(params, cimIParam{Name: "ResultClass", XML: xmlClassName(resultClass)})
+	}
+	if role != nil {
+		params = append(params, cimIParam{Name: "Role", XML: xmlValue(*role)})
+	}
+	if resultRole != nil {
+		params = append(params, cimIParam{Name: "ResultRole", XML: xmlValue(*resultRole)})
+	}
+	if propertyList != nil {
+		params = append(params, cimIParam{Name: "PropertyList", XML: xmlValueArray(propertyList)})
+	}
+	params = append(params, maxObjectCountParam(maxObjectCount))
+
+	resp, err := smis.invokeIntrinsicCtx(ctx, "OpenAssociatorInstances", params)
+	if err != nil {
+		return nil, err
+	}
+	return decodePullResult(resp)
+}
+
+///////////////////////////
+// PullInstancesWithPath //
+///////////////////////////
+
+func (smis *SMIS) PullInstancesWithPath(enumerationContext string, maxObjectCount int) (*PullResult, error) {
+	return smis.PullInstancesWithPathCtx(context.Background(), enumerationContext, maxObjectCount)
+}
+
+func (smis *SMIS) PullInstancesWithPathCtx(ctx context.Context, enumerationContext string, maxObjectCount int) (*PullResult, error) {
+	params := []cimIParam{
+		{Name: "EnumerationContext", XML: xmlValue(enumerationContext)},
+		maxObjectCountParam(maxObjectCount),
+	}
+
+	resp, err := smis.invokeIntrinsicCtx(ctx, "PullInstancesWithPath", params)
+	if err != nil {
+		return nil, err
+	}
+	return decodePullResult(resp)
+}
+
+//////////////////////
+// CloseEnumeration //
+//////////////////////
+
+func (smis *SMIS) CloseEnumeration(enumerationContext string) error {
+	return smis.CloseEnumerationCtx(context.Background(), enumerationContext)
+}
+
+func (smis *SMIS) CloseEnumerationCtx(ctx context.Context, enumerationContext string) error {
+	params := []cimIParam{{Name: "EnumerationContext", XML: xmlValue(enumerationContext)}}
+	_, err := smis.invokeIntrinsicCtx(ctx, "CloseEnumeration", params)
+	return err
+}
+
+// enumerationCloseTimeout bounds the CloseEnumeration sent when a read
+// stops early.  It does not use the caller's context, which may already be
+// done, and the CIM-XML client has no timeout of its own.
+const enumerationCloseTimeout = 30 * time.Second
+
+func (smis *SMIS) closeEnumeration(enumerationContext string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), enumerationCloseTimeout)
+	defer cancel()
+	return smis.CloseEnumerationCtx(ctx, enumerationContext)
+}
+
+// abortEnumeration closes an enumeration a read failed partway through and
+// returns the read error, noting a failed close.
+func (smis *SMIS) abortEnumeration(enumerationContext string, err error) error {
+	if enumerationContext == "" {
+		return err
+	}
+	if closeErr := smis.closeEnumeration(enumerationContext); closeErr != nil {
+		return fmt.Errorf("%w (close enumeration: %v)", err, closeErr)
+	}
+	return err
+}
+
+///////////////////////////////////////////////////////////////
+//      Iterator over the pages of an open enumeration       //
+//                                                           //
+//   for it.Next() {                                         //
+//       inst := it.Value()                                  //
+//   }                                                       //
+//   if err := it.Err(); err != nil { ... }                  //
+//   it.Close()                                              //
+///////////////////////////////////////////////////////////////
+
+type InstanceIterator struct {
+	smis               *SMIS
+	ctx                context.Context
+	maxObjectCount     int
+	enumerationContext string
+	endOfSequence      bool
+	buffer             []InstanceWithPath
+	current            InstanceWithPath
+	err                error
+}
+
+func (smis *SMIS) newInstanceIterator(ctx context.Context, first *PullResult, maxObjectCount int) *InstanceIterator {
+	return &InstanceIterator{
+		smis:               smis,
+		ctx:                ctx,
+		maxObjectCount:     maxObjectCount,
+		enumerationContext: first.EnumerationContext,
+		endOfSequence:      first.EndOfSequence,
+		buffer:             first.Instances,
+	}
+}
+
+// Next advances to the next instance, pulling another page from the
+// provider when the current one is used up.  It returns false at the end of
+// the enumeration or on error; check Err to tell them apart.
+func (it *InstanceIterator) Next() bool {
+	for len(it.buffer) == 0 {
+		if it.err != nil || it.endOfSequence {
+			return false
+		}
+		page, err := it.smis.PullInstancesWithPathCtx(it.ctx, it.enumerationContext, it.maxObjectCount)
+		if err != nil {
+			it.err = err
+			return false
+		}
+		it.buffer = page.Instances
+		it.enumerationContext = page.EnumerationContext
+		it.endOfSequence = page.EndOfSequence
+	}
+	it.current = it.buffer[0]
+	it.buffer = it.buffer[1:]
+	return true
+}
+
+func (it *InstanceIterator) Value() InstanceWithPath {
+	return it.current
+}
+
+func (it *InstanceIterator) Err() error {
+	return it.err
+}
+
+// Close releases the enumeration on the provider if it was not read to the
+// end, waiting at most enumerationCloseTimeout.  It is safe to call more
+// than once.
+func (it *InstanceIterator) Close() error {
+	if it.endOfSequence || it.enumerationContext == "" {
+		return nil
+	}
+	it.endOfSequence = true
+	it.buffer = nil
+	return it.smis.closeEnumeration(it.enumerationContext)
+}
+
+///////////////////////////////////////////////////////////////
+//         ITERATE over all instances of a class             //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) IterateInstances(className string, propertyList []string, maxObjectCount int) (*InstanceIterator, error) {
+	return smis.IterateInstancesCtx(context.Background(), className, propertyList, maxObjectCount)
+}
+
+func (smis *SMIS) IterateInstancesCtx(ctx context.Context, className string, propertyList []string, maxObjectCount int) (*InstanceIterator, error) {
+	first, err := smis.OpenEnumerateInstancesCtx(ctx, className, propertyList, maxObjectCount)
+	if err != nil {
+		return nil, err
+	}
+	return smis.newInstanceIterator(ctx, first, maxObjectCount), nil
+}
+
+///////////////////////////////////////////////////////////////
+//      ITERATE over all instances associated to a name      //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) IterateAssociatorInstances(instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string, propertyList []string, maxObjectCount int) (*InstanceIterator, error) {
+	return smis.IterateAssociatorInstancesCtx(context.Background(), instanceName, assocClass, resultClass, role, resultRole, propertyList, maxObjectCount)
+}
+
+func (smis *SMIS) IterateAssociatorInstancesCtx(ctx context.Context, instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string, propertyList []string, maxObjectCount int) (*InstanceIterator, error) {
+	first, err := smis.OpenAssociatorInstancesCtx(ctx, instanceName, assocClass, resultClass, role, resultRole, propertyList, maxObjectCount)
+	if err != nil {
+		return nil, err
+	}
+	return smis.newInstanceIterator(ctx, first, maxObjectCount), nil
+}
+
+// associatorPathsCtx lists the paths of the instances associated to a name
+// through the pull operations, one page at a time, so large lists do not
+// have to fit in a single response.  Instances are requested with an empty
+// property list.  Providers without pull support get a plain
+// AssociatorNames call.
+func (smis *SMIS) associatorPathsCtx(ctx context.Context, instanceName *gowbem.InstanceName, resultClass string) ([]gowbem.ObjectPath, error) {
+	it, err := smis.IterateAssociatorInstancesCtx(ctx, instanceName, "", resultClass, nil, nil, []string{}, 0)
+	var cimErr *CIMError
+	if errors.As(err, &cimErr) && cimErr.Code == CIMErrNotSupported {
+		return smis.AssociatorNamesCtx(ctx, instanceName, "", resultClass, nil, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	var paths []gowbem.ObjectPath
+	for it.Next() {
+		paths = append(paths, gowbem.ObjectPath{InstancePath: it.Value().InstancePath})
+	}
+	return paths, it.Err()
+}
+
+///////////////////////////////////////////////////////////////
+//      ENUMERATE every instance of an association class     //
+///////////////////////////////////////////////////////////////
+
+// EnumerateAssociations pulls every instance of an association class with
+// its reference properties, so the links between many objects can be read
+// in a few pages instead of one call per object.
+func (smis *SMIS) EnumerateAssociations(assocClass string) ([]Association, error) {
+	return smis.EnumerateAssociationsCtx(context.Background(), assocClass)
+}
+
+func (smis *SMIS) EnumerateAssociationsCtx(ctx context.Context, assocClass string) ([]Association, error) {
+	params := []cimIParam{
+		{Name: "ClassName", XML: xmlClassName(assocClass)},
+		maxObjectCountParam(0),
+	}
+	method := "OpenEnumerateInstances"
+
+	var associations []Association
+	var enumerationContext string
+	for {
+		resp, err := smis.invokeIntrinsicCtx(ctx, method, params)
+		if err != nil {
+			return nil, smis.abortEnumeration(enumerationContext, err)
+		}
+		var ret struct {
+			Associations []Association `xml:"VALUE.INSTANCEWITHPATH"`
+		}
+		pageContext, eos, err := decodePullPage(resp, &ret)
+		if err != nil {
+			return nil, smis.abortEnumeration(enumerationContext, err)
+		}
+		enumerationContext = pageContext
+		associations = append(associations, ret.Associations...)
+		if eos {
+			return associations, nil
+		}
+
+		method = "PullInstancesWithPath"
+		params = []cimIParam{
+			{Name: "EnumerationContext", XML: xmlValue(enumerationContext)},
+			maxObjectCountParam(0),
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////
+//            STREAM the list of Storage Volumes             //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) StreamVolumes(systemInstance *gowbem.InstanceName, maxObjectCount int) (*InstanceIterator, error) {
+	return smis.StreamVolumesCtx(context.Background(), systemInstance, maxObjectCount)
+}
+
+func (smis *SMIS) StreamVolumesCtx(ctx context.Context, systemInstance *gowbem.InstanceName, maxObjectCount int) (*InstanceIterator, error) {
+	return smis.IterateAssociatorInstancesCtx(ctx, systemInstance, "", "CIM_StorageVolume", nil, nil, volumeProperties, maxObjectCount)
+}
+
+///////////////////////////////////////////////////////////////
+//            STREAM the list of SCSI Initiators             //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) StreamScsiInitiators(systemInstance *gowbem.InstanceName, maxObjectCount int) (*InstanceIterator, error) {
+	return smis.StreamScsiInitiatorsCtx(context.Background(), systemInstance, maxObjectCount)
+}
+
+func (smis *SMIS) StreamScsiInitiatorsCtx(ctx context.Context, systemInstance *gowbem.InstanceName, maxObjectCount int) (*InstanceIterator, error) {
+	service, err := smis.GetStorageHardwareIDManagementServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	return smis.IterateAssociatorInstancesCtx(ctx, service, "", "SE_StorageHardwareID", nil, nil, nil, maxObjectCount)
+}
+
+///////////////////////////////////////////////////////////////
+//            STREAM the list of Masking Views               //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) StreamMaskingViews(systemInstance *gowbem.InstanceName, maxObjectCount int) (*InstanceIterator, error) {
+	return smis.StreamMaskingViewsCtx(context.Background(), systemInstance, maxObjectCount)
+}
+
+func (smis *SMIS) StreamMaskingViewsCtx(ctx context.Context, systemInstance *gowbem.InstanceName, maxObjectCount int) (*InstanceIterator, error) {
+	return smis.IterateAssociatorInstancesCtx(ctx, systemInstance, "", MaskingViewClass, nil, nil, nil, maxObjectCount)
+}
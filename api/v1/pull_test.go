@@ -0,0 +1,161 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"golang.org/x/net/context"
+)
+
+func TestIterateInstances(t *testing.T) {
+	it, err := smis.IterateInstances("Symm_StorageSystem", []string{"ElementName"}, 1)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer it.Close()
+
+	count := 0
+	for it.Next() {
+		name, _ := GetPropertyByName(it.Value().Instance, "ElementName")
+		fmt.Println("array=", name)
+		count++
+	}
+	if err := it.Err(); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if count == 0 {
+		t.Log("empty list")
+		t.Fail()
+	}
+}
+
+func TestStreamVolumes(t *testing.T) {
+	vols, err := smis.GetVolumes(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	it, err := smis.StreamVolumes(testingInstance, 100)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer it.Close()
+
+	count := 0
+	for it.Next() {
+		DumpInstanceClass(it.Value().InstancePath.InstanceName)
+		count++
+	}
+	if err := it.Err(); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if count != len(vols) {
+		t.Logf("streamed %d volumes, expected %d", count, len(vols))
+		t.Fail()
+	}
+}
+
+func TestStreamMaskingViewsClose(t *testing.T) {
+	it, err := smis.StreamMaskingViews(testingInstance, 1)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if it.Next() {
+		DumpInstanceClass(it.Value().InstancePath.InstanceName)
+	}
+	if err := it.Close(); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+}
+
+func TestStreamScsiInitiators(t *testing.T) {
+	it, err := smis.StreamScsiInitiators(testingInstance, 0)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer it.Close()
+
+	for it.Next() {
+		key, _ := GetKeyFromInstanceName(it.Value().InstancePath.InstanceName, "InstanceID")
+		fmt.Println("initiator=", key.(string))
+	}
+	if err := it.Err(); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+}
+
+// openPageCIMOM opens an enumeration that is not finished, fails every pull
+// and counts the CloseEnumeration calls.
+func openPageCIMOM(t *testing.T, closed *int32) (*SMIS, func()) {
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		method := r.Header.Get("CIMMethod")
+		switch method {
+		case "CloseEnumeration":
+			atomic.AddInt32(closed, 1)
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="CloseEnumeration"/>`)))
+		case "PullInstancesWithPath":
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="PullInstancesWithPath"><ERROR CODE="1" DESCRIPTION="provider failed"/></IMETHODRESPONSE>`)))
+		default:
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="` + method + `"><IRETURNVALUE></IRETURNVALUE>` +
+				`<PARAMVALUE NAME="EnumerationContext"><VALUE>ctx1</VALUE></PARAMVALUE>` +
+				`<PARAMVALUE NAME="EndOfSequence"><VALUE>FALSE</VALUE></PARAMVALUE></IMETHODRESPONSE>`)))
+		}
+	})
+	return client, server.Close
+}
+
+func TestEnumerateAssociationsClosesOnError(t *testing.T) {
+	var closed int32
+	client, closeServer := openPageCIMOM(t, &closed)
+	defer closeServer()
+
+	_, err := client.EnumerateAssociations("CIM_OrderedMemberOfCollection")
+	var cimErr *CIMError
+	if !errors.As(err, &cimErr) || cimErr.Code != CIMErrFailed {
+		t.Errorf("expected the pull error, got %v", err)
+	}
+	if atomic.LoadInt32(&closed) != 1 {
+		t.Error("enumeration not closed after the pull failed")
+	}
+}
+
+func TestInstanceIteratorCloseAfterCancel(t *testing.T) {
+	var closed int32
+	client, closeServer := openPageCIMOM(t, &closed)
+	defer closeServer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	it, err := client.IterateInstancesCtx(ctx, "Symm_StorageVolume", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if it.Next() {
+		t.Error("expected no instances")
+	}
+	if err = it.Close(); err != nil {
+		t.Error(err)
+	}
+	if atomic.LoadInt32(&closed) != 1 {
+		t.Error("enumeration not closed after the caller's context was done")
+	}
+}
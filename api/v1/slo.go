@@ -0,0 +1,104 @@
+package apiv1
+
+import (
+	"fmt"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+////////////////////////////////////////////////////////////////
+//      Service Level settings of a VMAX3 Storage Group       //
+////////////////////////////////////////////////////////////////
+
+type StorageGroupSLO struct {
+	SRP      string
+	SLO      string
+	Workload string
+}
+
+// findSLOCtx checks that the array offers slo with workload on srp.  An
+// empty workload matches any SLO entry without one.
+func (smis *SMIS) findSLOCtx(ctx context.Context, systemInstance *gowbem.InstanceName, srp, slo, workload string) (*SLO_Struct, error) {
+	SLOs, err := smis.GetSLOsCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+	for i, entry := range SLOs {
+		if entry.SRP != srp || entry.SLO_Name != slo {
+			continue
+		}
+		if entry.Workload == workload || (workload == "" && entry.Workload == "NONE") {
+			return &SLOs[i], nil
+		}
+	}
+	return nil, fmt.Errorf("SLO %s/%s on %s %w", slo, workload, srp, ErrNotFound)
+}
+
+///////////////////////////////////////////////////////////////
+//       CREATE a Storage Group with an SLO and workload     //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) CreateStorageGroupWithSLO(systemInstance *gowbem.InstanceName, groupName, srp, slo, workload string) (*gowbem.InstancePath, error) {
+	return smis.CreateStorageGroupWithSLOCtx(context.Background(), systemInstance, groupName, srp, slo, workload)
+}
+
+func (smis *SMIS) CreateStorageGroupWithSLOCtx(ctx context.Context, systemInstance *gowbem.InstanceName, groupName, srp, slo, workload string) (*gowbem.InstancePath, error) {
+	if _, err := smis.findSLOCtx(ctx, systemInstance, srp, slo, workload); err != nil {
+		return nil, err
+	}
+
+	var params []gowbem.IParamValue
+	params = append(params, gowbem.IParamValue{Name: "EMCSRP", Value: &gowbem.Value{srp}})
+	params = append(params, gowbem.IParamValue{Name: "EMCSLO", Value: &gowbem.Value{slo}})
+	if workload != "" {
+		params = append(params, gowbem.IParamValue{Name: "EMCWorkload", Value: &gowbem.Value{workload}})
+	}
+	return smis.createGroupCtx(ctx, systemInstance, groupName, GroupTypeStorage, params)
+}
+
+///////////////////////////////////////////////////////////////
+//         GET the SLO and workload of a Storage Group       //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetStorageGroupSLO(group *gowbem.InstanceName) (*StorageGroupSLO, error) {
+	return smis.GetStorageGroupSLOCtx(context.Background(), group)
+}
+
+func (smis *SMIS) GetStorageGroupSLOCtx(ctx context.Context, group *gowbem.InstanceName) (*StorageGroupSLO, error) {
+	instance, err := smis.GetInstanceCtx(ctx, group, false, []string{"EMCSRP", "EMCSLO", "EMCWorkload"})
+	if err != nil {
+		return nil, err
+	}
+	return &StorageGroupSLO{
+		SRP:      propertyString(instance, "EMCSRP"),
+		SLO:      propertyString(instance, "EMCSLO"),
+		Workload: propertyString(instance, "EMCWorkload"),
+	}, nil
+}
+
+///////////////////////////////////////////////////////////////
+//   SET the SLO and workload of a Storage Group in place    //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) SetStorageGroupSLO(systemInstance *gowbem.InstanceName, group *gowbem.InstanceName, slo, workload string) error {
+	return smis.SetStorageGroupSLOCtx(context.Background(), systemInstance, group, slo, workload)
+}
+
+func (smis *SMIS) SetStorageGroupSLOCtx(ctx context.Context, systemInstance *gowbem.InstanceName, group *gowbem.InstanceName, slo, workload string) error {
+	current, err := smis.GetStorageGroupSLOCtx(ctx, group)
+	if err != nil {
+		return err
+	}
+	if _, err = smis.findSLOCtx(ctx, systemInstance, current.SRP, slo, workload); err != nil {
+		return err
+	}
+
+	if workload == "" {
+		workload = "NONE"
+	}
+	return smis.ModifyInstanceCtx(ctx, group, map[string]interface{}{
+		"EMCSLO":      slo,
+		"EMCWorkload": workload,
+	})
+}
@@ -0,0 +1,61 @@
+package apiv1
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestStorageGroupSLO(t *testing.T) {
+	if !smis.IsArrayV3(testingInstance) {
+		return
+	}
+	SLOs, err := smis.GetSLOs(testingInstance)
+	if err != nil || len(SLOs) < 2 {
+		t.Log("not enough SLOs", err)
+		t.Fail()
+		return
+	}
+	first, second := SLOs[0], SLOs[len(SLOs)-1]
+
+	group, err := smis.CreateStorageGroupWithSLO(testingInstance, "govmax_test_slo_sg", first.SRP, first.SLO_Name, first.Workload)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	defer smis.PostDeleteGroup(testingInstance, group, true)
+
+	current, err := smis.GetStorageGroupSLO(group.InstanceName)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	fmt.Printf("%+v\n", *current)
+	if current.SLO != first.SLO_Name {
+		t.Log("SLO mismatch: " + current.SLO)
+		t.Fail()
+	}
+
+	if err = smis.SetStorageGroupSLO(testingInstance, group.InstanceName, second.SLO_Name, second.Workload); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	current, _ = smis.GetStorageGroupSLO(group.InstanceName)
+	if current == nil || current.SLO != second.SLO_Name {
+		t.Log("SLO not changed")
+		t.Fail()
+	}
+}
+
+func TestCreateStorageGroupBadSLO(t *testing.T) {
+	if !smis.IsArrayV3(testingInstance) {
+		return
+	}
+	_, err := smis.CreateStorageGroupWithSLO(testingInstance, "govmax_test_bad_slo", "SRP_1", "NoSuchSLO", "")
+	if err == nil {
+		t.Log("expected an error for an unknown SLO")
+		t.Fail()
+	}
+}
@@ -3,10 +3,14 @@ package apiv1
 import (
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"strconv"
+	"sync"
 
 	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
 )
 
 type SMIS struct {
@@ -17,6 +21,8 @@ type SMIS struct {
 	password string
 	client   *http.Client
 	conn     *gowbem.WBEMConnection
+	waiter   *JobWaiter
+	lock     sync.Mutex
 }
 
 func New(host string, port string, insecure bool, username string, password string) (*SMIS, error) {
@@ -34,7 +40,14 @@ func New(host string, port string, insecure bool, username string, password stri
 		client = &http.Client{}
 	}
 
-	return &SMIS{host, port, insecure, username, password, client, nil}, nil
+	return &SMIS{
+		host:     host,
+		port:     port,
+		insecure: insecure,
+		username: username,
+		password: password,
+		client:   client,
+	}, nil
 }
 
 /////////////////
@@ -52,7 +65,7 @@ func getArrayUrl(smis *SMIS) string {
 		Scheme: schema,
 		User:   url.UserPassword(smis.username, smis.password),
 		Host:   smis.host + ":" + smis.port,
-		Path:   "/root/emc",
+		Path:   "/" + smisNamespace,
 	}
 	return path.String()
 }
@@ -62,6 +75,8 @@ func getArrayUrl(smis *SMIS) string {
 /////////////////
 
 func GetWBEMConn(smis *SMIS) (*gowbem.WBEMConnection, error) {
+	smis.lock.Lock()
+	defer smis.lock.Unlock()
 	if smis.conn == nil {
 		c, e := gowbem.NewWBEMConn(getArrayUrl(smis))
 		if nil != e {
@@ -72,16 +87,89 @@ func GetWBEMConn(smis *SMIS) (*gowbem.WBEMConnection, error) {
 	return smis.conn, nil
 }
 
+/////////////
+// withCtx //
+/////////////
+
+// withCtx runs fn unless ctx is already done.  It is only used for the
+// gowbem calls that have no CIM-XML equivalent here; those cannot be
+// interrupted once sent, so a deadline is checked before the call only.
+func withCtx(ctx context.Context, fn func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return fn()
+}
+
+// The operations below are sent over the SMIS http.Client with the ctx
+// attached to the request, so cancelling ctx aborts the request in flight.
+
+// intrinsicFlags encodes the flags and property list shared by the
+// instance returning operations.  Where the operation has a LocalOnly flag
+// it is sent as false, so inherited properties are returned too.
+func intrinsicFlags(hasLocalOnly bool, includeClassOrigin bool, propertyList []string) []cimIParam {
+	var params []cimIParam
+	if hasLocalOnly {
+		params = append(params, cimIParam{Name: "LocalOnly", XML: xmlValue("false")})
+	}
+	params = append(params, cimIParam{Name: "IncludeClassOrigin", XML: xmlValue(strconv.FormatBool(includeClassOrigin))})
+	if propertyList != nil {
+		params = append(params, cimIParam{Name: "PropertyList", XML: xmlValueArray(propertyList)})
+	}
+	return params
+}
+
+// associationParams encodes the filters shared by the association
+// operations; empty class names and nil roles are left out.
+func associationParams(instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string) []cimIParam {
+	params := []cimIParam{{Name: "ObjectName", XML: xmlInstanceName(instanceName)}}
+	if assocClass != "" {
+		params = append(params, cimIParam{Name: "AssocClass", XML: xmlClassName(assocClass)})
+	}
+	if resultClass != "" {
+		params = append(params, cimIParam{Name: "ResultClass", XML: xmlClassName(resultClass)})
+	}
+	if role != nil {
+		params = append(params, cimIParam{Name: "Role", XML: xmlValue(*role)})
+	}
+	if resultRole != nil {
+		params = append(params, cimIParam{Name: "ResultRole", XML: xmlValue(*resultRole)})
+	}
+	return params
+}
+
+type cimObjectPath struct {
+	InstancePath *gowbem.InstancePath `xml:"INSTANCEPATH"`
+}
+
+func objectPaths(paths []cimObjectPath) []gowbem.ObjectPath {
+	var objects []gowbem.ObjectPath
+	for _, path := range paths {
+		objects = append(objects, gowbem.ObjectPath{InstancePath: path.InstancePath})
+	}
+	return objects
+}
+
 ////////////////////////////
 // EnumerateInstanceNames //
 ////////////////////////////
 
 func (smis *SMIS) EnumerateInstanceNames(classname string) ([]gowbem.InstanceName, error) {
-	c, e := GetWBEMConn(smis)
-	if nil != e {
-		return nil, e
+	return smis.EnumerateInstanceNamesCtx(context.Background(), classname)
+}
+
+func (smis *SMIS) EnumerateInstanceNamesCtx(ctx context.Context, classname string) ([]gowbem.InstanceName, error) {
+	resp, err := smis.invokeIntrinsicCtx(ctx, "EnumerateInstanceNames", []cimIParam{{Name: "ClassName", XML: xmlClassName(classname)}})
+	if err != nil {
+		return nil, err
+	}
+	var ret struct {
+		Names []gowbem.InstanceName `xml:"INSTANCENAME"`
+	}
+	if err = resp.decodeReturn(&ret); err != nil {
+		return nil, err
 	}
-	return c.EnumerateInstanceNames(MakeClassName(classname))
+	return ret.Names, nil
 }
 
 ////////////////////////
@@ -89,11 +177,32 @@ func (smis *SMIS) EnumerateInstanceNames(classname string) ([]gowbem.InstanceNam
 ////////////////////////
 
 func (smis *SMIS) EnumerateInstances(className string, deepInheritance bool, includeClassOrigin bool, propertyList []string) ([]gowbem.ValueNamedInstance, error) {
-	c, e := GetWBEMConn(smis)
-	if nil != e {
-		return nil, e
+	return smis.EnumerateInstancesCtx(context.Background(), className, deepInheritance, includeClassOrigin, propertyList)
+}
+
+func (smis *SMIS) EnumerateInstancesCtx(ctx context.Context, className string, deepInheritance bool, includeClassOrigin bool, propertyList []string) ([]gowbem.ValueNamedInstance, error) {
+	params := []cimIParam{
+		{Name: "ClassName", XML: xmlClassName(className)},
+		{Name: "DeepInheritance", XML: xmlValue(strconv.FormatBool(deepInheritance))},
 	}
-	return c.EnumerateInstances(&gowbem.ClassName{Name: className}, deepInheritance, includeClassOrigin, propertyList)
+	resp, err := smis.invokeIntrinsicCtx(ctx, "EnumerateInstances", append(params, intrinsicFlags(true, includeClassOrigin, propertyList)...))
+	if err != nil {
+		return nil, err
+	}
+	var ret struct {
+		Instances []struct {
+			InstanceName *gowbem.InstanceName `xml:"INSTANCENAME"`
+			Instance     *gowbem.Instance     `xml:"INSTANCE"`
+		} `xml:"VALUE.NAMEDINSTANCE"`
+	}
+	if err = resp.decodeReturn(&ret); err != nil {
+		return nil, err
+	}
+	var instances []gowbem.ValueNamedInstance
+	for _, inst := range ret.Instances {
+		instances = append(instances, gowbem.ValueNamedInstance{InstanceName: inst.InstanceName, Instance: inst.Instance})
+	}
+	return instances, nil
 }
 
 /////////////////
@@ -101,15 +210,25 @@ func (smis *SMIS) EnumerateInstances(className string, deepInheritance bool, inc
 /////////////////
 
 func (smis *SMIS) GetInstance(instanceName *gowbem.InstanceName, includeClassOrigin bool, propertyList []string) (*gowbem.Instance, error) {
-	c, e := GetWBEMConn(smis)
-	if nil != e {
-		return nil, e
-	}
-	inst, err := c.GetInstance(instanceName, includeClassOrigin, propertyList)
+	return smis.GetInstanceCtx(context.Background(), instanceName, includeClassOrigin, propertyList)
+}
+
+func (smis *SMIS) GetInstanceCtx(ctx context.Context, instanceName *gowbem.InstanceName, includeClassOrigin bool, propertyList []string) (*gowbem.Instance, error) {
+	params := []cimIParam{{Name: "InstanceName", XML: xmlInstanceName(instanceName)}}
+	resp, err := smis.invokeIntrinsicCtx(ctx, "GetInstance", append(params, intrinsicFlags(true, includeClassOrigin, propertyList)...))
 	if err != nil {
 		return nil, err
 	}
-	return &inst[0], err
+	var ret struct {
+		Instances []gowbem.Instance `xml:"INSTANCE"`
+	}
+	if err = resp.decodeReturn(&ret); err != nil {
+		return nil, err
+	}
+	if len(ret.Instances) == 0 {
+		return nil, fmt.Errorf("Instance %s %w", instanceName.ClassName, ErrNotFound)
+	}
+	return &ret.Instances[0], nil
 }
 
 /////////////////////
@@ -117,11 +236,21 @@ func (smis *SMIS) GetInstance(instanceName *gowbem.InstanceName, includeClassOri
 /////////////////////
 
 func (smis *SMIS) AssociatorNames(instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string) ([]gowbem.ObjectPath, error) {
-	c, e := GetWBEMConn(smis)
-	if nil != e {
-		return nil, e
+	return smis.AssociatorNamesCtx(context.Background(), instanceName, assocClass, resultClass, role, resultRole)
+}
+
+func (smis *SMIS) AssociatorNamesCtx(ctx context.Context, instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string) ([]gowbem.ObjectPath, error) {
+	resp, err := smis.invokeIntrinsicCtx(ctx, "AssociatorNames", associationParams(instanceName, assocClass, resultClass, role, resultRole))
+	if err != nil {
+		return nil, err
+	}
+	var ret struct {
+		Paths []cimObjectPath `xml:"OBJECTPATH"`
+	}
+	if err = resp.decodeReturn(&ret); err != nil {
+		return nil, err
 	}
-	return c.AssociatorNames(MakeObjectName(nil, instanceName), MakeClassName(assocClass), MakeClassName(resultClass), role, resultRole)
+	return objectPaths(ret.Paths), nil
 }
 
 /////////////////////////
@@ -129,11 +258,26 @@ func (smis *SMIS) AssociatorNames(instanceName *gowbem.InstanceName, assocClass,
 /////////////////////////
 
 func (smis *SMIS) AssociatorInstances(instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string, includeClassOrigin bool, propertyList []string) ([]gowbem.ValueObjectWithPath, error) {
-	c, e := GetWBEMConn(smis)
-	if nil != e {
-		return nil, e
+	return smis.AssociatorInstancesCtx(context.Background(), instanceName, assocClass, resultClass, role, resultRole, includeClassOrigin, propertyList)
+}
+
+func (smis *SMIS) AssociatorInstancesCtx(ctx context.Context, instanceName *gowbem.InstanceName, assocClass, resultClass string, role, resultRole *string, includeClassOrigin bool, propertyList []string) ([]gowbem.ValueObjectWithPath, error) {
+	params := associationParams(instanceName, assocClass, resultClass, role, resultRole)
+	resp, err := smis.invokeIntrinsicCtx(ctx, "Associators", append(params, intrinsicFlags(false, includeClassOrigin, propertyList)...))
+	if err != nil {
+		return nil, err
+	}
+	var ret struct {
+		Objects []InstanceWithPath `xml:"VALUE.OBJECTWITHPATH"`
+	}
+	if err = resp.decodeReturn(&ret); err != nil {
+		return nil, err
+	}
+	var objects []gowbem.ValueObjectWithPath
+	for _, object := range ret.Objects {
+		objects = append(objects, gowbem.ValueObjectWithPath{InstancePath: object.InstancePath, Instance: object.Instance})
 	}
-	return c.Associators(MakeObjectName(nil, instanceName), MakeClassName(assocClass), MakeClassName(resultClass), role, resultRole, includeClassOrigin, propertyList)
+	return objects, nil
 }
 
 ////////////////////
@@ -141,11 +285,28 @@ func (smis *SMIS) AssociatorInstances(instanceName *gowbem.InstanceName, assocCl
 ////////////////////
 
 func (smis *SMIS) ReferenceNames(instanceName *gowbem.InstanceName, assocClass string, role *string) ([]gowbem.ObjectPath, error) {
-	c, e := GetWBEMConn(smis)
-	if nil != e {
-		return nil, e
+	return smis.ReferenceNamesCtx(context.Background(), instanceName, assocClass, role)
+}
+
+func (smis *SMIS) ReferenceNamesCtx(ctx context.Context, instanceName *gowbem.InstanceName, assocClass string, role *string) ([]gowbem.ObjectPath, error) {
+	params := []cimIParam{{Name: "ObjectName", XML: xmlInstanceName(instanceName)}}
+	if assocClass != "" {
+		params = append(params, cimIParam{Name: "ResultClass", XML: xmlClassName(assocClass)})
+	}
+	if role != nil {
+		params = append(params, cimIParam{Name: "Role", XML: xmlValue(*role)})
+	}
+	resp, err := smis.invokeIntrinsicCtx(ctx, "ReferenceNames", params)
+	if err != nil {
+		return nil, err
 	}
-	return c.ReferenceNames(MakeObjectName(nil, instanceName), MakeClassName(assocClass), role)
+	var ret struct {
+		Paths []cimObjectPath `xml:"OBJECTPATH"`
+	}
+	if err = resp.decodeReturn(&ret); err != nil {
+		return nil, err
+	}
+	return objectPaths(ret.Paths), nil
 }
 
 /////////////////////////
@@ -153,11 +314,23 @@ func (smis *SMIS) ReferenceNames(instanceName *gowbem.InstanceName, assocClass s
 /////////////////////////
 
 func (smis *SMIS) EnumerateClassNames(className string, deepInheritance bool) ([]gowbem.Class, error) {
+	return smis.EnumerateClassNamesCtx(context.Background(), className, deepInheritance)
+}
+
+func (smis *SMIS) EnumerateClassNamesCtx(ctx context.Context, className string, deepInheritance bool) ([]gowbem.Class, error) {
 	c, e := GetWBEMConn(smis)
 	if nil != e {
 		return nil, e
 	}
-	return c.EnumerateClassNames(MakeClassName(className), deepInheritance)
+	var classes []gowbem.Class
+	e = withCtx(ctx, func() (err error) {
+		classes, err = c.EnumerateClassNames(MakeClassName(className), deepInheritance)
+		return err
+	})
+	if e != nil {
+		return nil, e
+	}
+	return classes, nil
 }
 
 //////////////////
@@ -165,12 +338,11 @@ func (smis *SMIS) EnumerateClassNames(className string, deepInheritance bool) ([
 //////////////////
 
 func (smis *SMIS) InvokeMethod(instanceName *gowbem.InstanceName, methodName string, paramValues []gowbem.IParamValue) (int, []gowbem.ParamValue, error) {
-	c, e := GetWBEMConn(smis)
-	if nil != e {
-		return -1, nil, e
-	}
+	return smis.InvokeMethodCtx(context.Background(), instanceName, methodName, paramValues)
+}
 
-	return c.InvokeMethod(MakeObjectName(nil, instanceName), methodName, paramValues)
+func (smis *SMIS) InvokeMethodCtx(ctx context.Context, instanceName *gowbem.InstanceName, methodName string, paramValues []gowbem.IParamValue) (int, []gowbem.ParamValue, error) {
+	return smis.invokeExtrinsicCtx(ctx, instanceName, methodName, paramValues)
 }
 
 //////////////////
@@ -183,7 +355,24 @@ func GetKeyFromInstanceName(instanceName *gowbem.InstanceName, keyName string) (
 			return key.KeyValue.KeyValue, nil
 		}
 	}
-	return "", errors.New("Key not found")
+	return "", fmt.Errorf("Key %s %w", keyName, ErrNotFound)
+}
+
+///////////////
+// keyString //
+///////////////
+
+// keyString returns the named key of an instance name as a string.
+func keyString(instanceName *gowbem.InstanceName, keyName string) (string, error) {
+	value, err := GetKeyFromInstanceName(instanceName, keyName)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("Key %s of %s is not a string", keyName, instanceName.ClassName)
+	}
+	return s, nil
 }
 
 ///////////////////////
@@ -196,7 +385,42 @@ func GetPropertyByName(instance *gowbem.Instance, name string) (interface{}, err
 			return pr.Value.Value, nil
 		}
 	}
-	return "", errors.New("Property not found")
+	return "", fmt.Errorf("Property %s %w", name, ErrNotFound)
+}
+
+////////////////////
+// propertyString //
+////////////////////
+
+// propertyString returns the named property as a string, or "" when the
+// instance does not carry it.
+func propertyString(instance *gowbem.Instance, name string) string {
+	value, err := GetPropertyByName(instance, name)
+	if err != nil || value == nil {
+		return ""
+	}
+	if s, ok := value.(string); ok {
+		return s
+	}
+	return fmt.Sprint(value)
+}
+
+////////////////////
+// propertyUint64 //
+////////////////////
+
+func propertyUint64(instance *gowbem.Instance, name string) uint64 {
+	n, _ := strconv.ParseUint(propertyString(instance, name), 10, 64)
+	return n
+}
+
+//////////////////
+// propertyBool //
+//////////////////
+
+func propertyBool(instance *gowbem.Instance, name string) bool {
+	b, _ := strconv.ParseBool(propertyString(instance, name))
+	return b
 }
 
 ///////////////////
@@ -0,0 +1,216 @@
+package apiv1
+
+import (
+	"errors"
+	"strconv"
+)
+
+///////////////////////////////////////////////////////////////
+//          Type of group created by CreateGroup             //
+///////////////////////////////////////////////////////////////
+
+type GroupType int
+
+const (
+	GroupTypeInitiator GroupType = 2
+	GroupTypePort      GroupType = 3
+	GroupTypeStorage   GroupType = 4
+)
+
+var groupTypeNames = map[GroupType]string{
+	GroupTypeInitiator: "InitiatorGroup",
+	GroupTypePort:      "PortGroup",
+	GroupTypeStorage:   "StorageGroup",
+}
+
+func (t GroupType) String() string {
+	if name, ok := groupTypeNames[t]; ok {
+		return name
+	}
+	return "GroupType(" + strconv.Itoa(int(t)) + ")"
+}
+
+func (t GroupType) Validate() error {
+	if _, ok := groupTypeNames[t]; !ok {
+		return errors.New("Invalid group type " + t.String())
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+//    Type of ID given to CreateStorageHardwareID            //
+///////////////////////////////////////////////////////////////
+
+type HardwareIDType int
+
+const (
+	HardwareIDTypeWWN HardwareIDType = 2
+	HardwareIDTypeIQN HardwareIDType = 5
+)
+
+var hardwareIDTypeNames = map[HardwareIDType]string{
+	HardwareIDTypeWWN: "PortWWN",
+	HardwareIDTypeIQN: "iSCSIName",
+}
+
+func (t HardwareIDType) String() string {
+	if name, ok := hardwareIDTypeNames[t]; ok {
+		return name
+	}
+	return "HardwareIDType(" + strconv.Itoa(int(t)) + ")"
+}
+
+func (t HardwareIDType) Validate() error {
+	if _, ok := hardwareIDTypeNames[t]; !ok {
+		return errors.New("Invalid hardware ID type " + t.String())
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+//     Type of element created from a Storage Pool           //
+///////////////////////////////////////////////////////////////
+
+type ElementType int
+
+const (
+	ElementTypeStorageVolume     ElementType = 2
+	ElementTypeStorageExtent     ElementType = 3
+	ElementTypeLogicalDisk       ElementType = 4
+	ElementTypeThinStorageVolume ElementType = 5
+	ElementTypeThinLogicalDisk   ElementType = 6
+)
+
+var elementTypeNames = map[ElementType]string{
+	ElementTypeStorageVolume:     "StorageVolume",
+	ElementTypeStorageExtent:     "StorageExtent",
+	ElementTypeLogicalDisk:       "LogicalDisk",
+	ElementTypeThinStorageVolume: "ThinlyProvisionedStorageVolume",
+	ElementTypeThinLogicalDisk:   "ThinlyProvisionedLogicalDisk",
+}
+
+func (t ElementType) String() string {
+	if name, ok := elementTypeNames[t]; ok {
+		return name
+	}
+	return "ElementType(" + strconv.Itoa(int(t)) + ")"
+}
+
+func (t ElementType) Validate() error {
+	if _, ok := elementTypeNames[t]; !ok {
+		return errors.New("Invalid element type " + t.String())
+	}
+	return nil
+}
+
+// Value is the ElementType as set in PostVolumesReq.
+func (t ElementType) Value() string {
+	return strconv.Itoa(int(t))
+}
+
+// ParseElementType reads the ElementType of a PostVolumesReq.
+func ParseElementType(value string) (ElementType, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, errors.New("Invalid element type " + value)
+	}
+	t := ElementType(n)
+	return t, t.Validate()
+}
+
+///////////////////////////////////////////////////////////////
+//            JobState of a CIM_ConcreteJob                  //
+///////////////////////////////////////////////////////////////
+
+type JobState int
+
+const (
+	JobStateNew          JobState = 2
+	JobStateStarting     JobState = 3
+	JobStateRunning      JobState = 4
+	JobStateSuspended    JobState = 5
+	JobStateShuttingDown JobState = 6
+	JobStateCompleted    JobState = 7
+	JobStateTerminated   JobState = 8
+	JobStateKilled       JobState = 9
+	JobStateException    JobState = 10
+	JobStateService      JobState = 11
+	JobStateQueryPending JobState = 12
+)
+
+// String returns the name used by GetJobStatus, or "UNKNOWN".
+func (s JobState) String() string {
+	if name, ok := jobStatusMap[s]; ok {
+		return name
+	}
+	return "UNKNOWN"
+}
+
+func (s JobState) Validate() error {
+	if _, ok := jobStatusMap[s]; !ok {
+		return errors.New("Invalid job state " + strconv.Itoa(int(s)))
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+//    Protocol of a front end port, from its ConnectionType  //
+///////////////////////////////////////////////////////////////
+
+type FrontEndProtocol int
+
+const (
+	FrontEndProtocolFC    FrontEndProtocol = 2
+	FrontEndProtocolISCSI FrontEndProtocol = 7
+)
+
+var frontEndProtocolNames = map[FrontEndProtocol]string{
+	FrontEndProtocolFC:    "FC",
+	FrontEndProtocolISCSI: "iSCSI",
+}
+
+func (p FrontEndProtocol) String() string {
+	if name, ok := frontEndProtocolNames[p]; ok {
+		return name
+	}
+	return "FrontEndProtocol(" + strconv.Itoa(int(p)) + ")"
+}
+
+func (p FrontEndProtocol) Validate() error {
+	if _, ok := frontEndProtocolNames[p]; !ok {
+		return errors.New("Invalid front end protocol " + p.String())
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+//    Status of a front end port, from its EnabledState      //
+///////////////////////////////////////////////////////////////
+
+type PortStatus int
+
+const (
+	PortStatusUnknown        PortStatus = 0
+	PortStatusEnabled        PortStatus = 2
+	PortStatusDisabled       PortStatus = 3
+	PortStatusEnabledOffline PortStatus = 6
+)
+
+var portStatusNames = map[PortStatus]string{
+	PortStatusUnknown:        "Unknown",
+	PortStatusEnabled:        "Enabled",
+	PortStatusDisabled:       "Disabled",
+	PortStatusEnabledOffline: "EnabledButOffline",
+}
+
+func (s PortStatus) String() string {
+	if name, ok := portStatusNames[s]; ok {
+		return name
+	}
+	return "PortStatus(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Online reports whether the port is enabled and able to carry I/O.
+func (s PortStatus) Online() bool {
+	return s == PortStatusEnabled
+}
@@ -0,0 +1,60 @@
+package apiv1
+
+import (
+	"testing"
+)
+
+func TestEnumStrings(t *testing.T) {
+	if GroupTypeStorage.String() != "StorageGroup" || HardwareIDTypeIQN.String() != "iSCSIName" {
+		t.Log("unexpected enum name")
+		t.Fail()
+	}
+	if JobStateCompleted.String() != "COMPLETED" || JobState(42).String() != "UNKNOWN" {
+		t.Log("unexpected job state name")
+		t.Fail()
+	}
+	if GroupType(7).Validate() == nil || HardwareIDType(3).Validate() == nil {
+		t.Log("invalid values accepted")
+		t.Fail()
+	}
+	if _, err := ParseElementType("x"); err == nil {
+		t.Log("invalid element type accepted")
+		t.Fail()
+	}
+}
+
+func TestCreateGroupInvalidType(t *testing.T) {
+	if _, err := smis.CreateGroup(testingInstance, "govmax_test_bad_type", GroupType(1)); err == nil {
+		t.Log("expected an error for an invalid group type")
+		t.Fail()
+	}
+	if _, err := smis.CreateStorageHardwareID(testingInstance, "10000000C94E5D22", HardwareIDType(3)); err == nil {
+		t.Log("expected an error for an invalid hardware ID type")
+		t.Fail()
+	}
+}
+
+func TestCreateGroup(t *testing.T) {
+	group, err := smis.CreateGroup(testingInstance, "govmax_test_typed_pg", GroupTypePort)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if err = smis.PostDeleteGroup(testingInstance, group, true); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+	}
+}
+
+func TestNewPostVolumesReq(t *testing.T) {
+	req, err := NewPostVolumesReq("govmax_test_vol", ElementTypeThinStorageVolume, 2, nil, 1073741824)
+	if err != nil || req.ElementType != "5" || req.EMCNumberOfDevices != "2" || req.Size != "1073741824" {
+		t.Log("unexpected request", req, err)
+		t.Fail()
+	}
+	if _, err := NewPostVolumesReq("govmax_test_vol", ElementType(9), 1, nil, 1); err == nil {
+		t.Log("invalid element type accepted")
+		t.Fail()
+	}
+}
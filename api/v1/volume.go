@@ -0,0 +1,434 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+	"golang.org/x/net/context"
+)
+
+////////////////////////////////////////////////////////////////
+//      Struct used to store decoded Storage Volume fields    //
+////////////////////////////////////////////////////////////////
+
+type Volume struct {
+	InstanceName      *gowbem.InstanceName
+	DeviceID          string
+	ElementName       string
+	CapacityBytes     uint64
+	ConsumableBlocks  uint64
+	BlockSize         uint64
+	WWN               string
+	Pool              string
+	SLO               string
+	Workload          string
+	Status            string
+	ThinlyProvisioned bool
+	Mapped            bool
+}
+
+// volumeProperties is the property list requested when volumes are fetched
+// in bulk; it must cover every property read by DecodeVolume.
+var volumeProperties = []string{
+	"DeviceID",
+	"ElementName",
+	"NumberOfBlocks",
+	"ConsumableBlocks",
+	"BlockSize",
+	"EMCWWN",
+	"Name",
+	"EMCSRP",
+	"PoolName",
+	"EMCSLO",
+	"EMCWorkload",
+	"Status",
+	"ThinlyProvisioned",
+	"EMCIsMapped",
+}
+
+////////////////////////////////////////////////////////////////
+//        DECODE a Storage Volume from its CIM instance       //
+//                                                            //
+//   CapacityBytes is NumberOfBlocks * BlockSize, the WWN is  //
+//   EMCWWN (falling back to the NAA Name), and Pool is the   //
+//   SRP on a VMAX3 or the virtual pool on older arrays.      //
+////////////////////////////////////////////////////////////////
+
+func DecodeVolume(name *gowbem.InstanceName, instance *gowbem.Instance) (*Volume, error) {
+	if instance == nil {
+		return nil, errors.New("Volume instance is nil")
+	}
+	deviceID := propertyString(instance, "DeviceID")
+	if deviceID == "" && name != nil {
+		if key, err := GetKeyFromInstanceName(name, "DeviceID"); err == nil {
+			deviceID, _ = key.(string)
+		}
+	}
+	if deviceID == "" {
+		return nil, errors.New("Volume DeviceID not found")
+	}
+
+	vol := &Volume{
+		InstanceName:      name,
+		DeviceID:          deviceID,
+		ElementName:       propertyString(instance, "ElementName"),
+		ConsumableBlocks:  propertyUint64(instance, "ConsumableBlocks"),
+		BlockSize:         propertyUint64(instance, "BlockSize"),
+		WWN:               propertyString(instance, "EMCWWN"),
+		Pool:              propertyString(instance, "EMCSRP"),
+		SLO:               propertyString(instance, "EMCSLO"),
+		Workload:          propertyString(instance, "EMCWorkload"),
+		Status:            propertyString(instance, "Status"),
+		ThinlyProvisioned: propertyBool(instance, "ThinlyProvisioned"),
+		Mapped:            propertyBool(instance, "EMCIsMapped"),
+	}
+	vol.CapacityBytes = propertyUint64(instance, "NumberOfBlocks") * vol.BlockSize
+	if vol.WWN == "" {
+		vol.WWN = propertyString(instance, "Name")
+	}
+	if vol.Pool == "" {
+		vol.Pool = propertyString(instance, "PoolName")
+	}
+	return vol, nil
+}
+
+///////////////////////////////////////////////////////////
+//            GET a Storage Volume's details             //
+///////////////////////////////////////////////////////////
+
+func (smis *SMIS) GetVolumeDetails(systemInstance *gowbem.InstanceName, volumeID string) (*Volume, error) {
+	return smis.GetVolumeDetailsCtx(context.Background(), systemInstance, volumeID)
+}
+
+func (smis *SMIS) GetVolumeDetailsCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volumeID string) (*Volume, error) {
+	volumeName, err := smis.GetVolumeByIDCtx(ctx, systemInstance, volumeID)
+	if err != nil {
+		return nil, err
+	}
+	volumeInstance, err := smis.GetInstanceCtx(ctx, volumeName, false, nil)
+	if err != nil {
+		return nil, err
+	}
+	return DecodeVolume(volumeName, volumeInstance)
+}
+
+///////////////////////////////////////////////////////////////
+//         GET a list of Storage Volumes' details            //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ListVolumeDetails(systemInstance *gowbem.InstanceName) ([]Volume, error) {
+	return smis.ListVolumeDetailsCtx(context.Background(), systemInstance)
+}
+
+// ListVolumeDetailsCtx pages through the volumes with StreamVolumes and
+// decodes each page as it arrives, so the provider never has to send every
+// volume of the array in one response.  Providers without pull support get
+// a single Associators call.
+func (smis *SMIS) ListVolumeDetailsCtx(ctx context.Context, systemInstance *gowbem.InstanceName) ([]Volume, error) {
+	var details []Volume
+	var errs []error
+	decode := func(volume InstanceWithPath) {
+		var name *gowbem.InstanceName
+		if volume.InstancePath != nil {
+			name = volume.InstancePath.InstanceName
+		}
+		vol, err := DecodeVolume(name, volume.Instance)
+		if err != nil {
+			errs = append(errs, err)
+			return
+		}
+		details = append(details, *vol)
+	}
+
+	it, err := smis.StreamVolumesCtx(ctx, systemInstance, 0)
+	var cimErr *CIMError
+	if errors.As(err, &cimErr) && cimErr.Code == CIMErrNotSupported {
+		volumes, err := smis.AssociatorInstancesCtx(ctx, systemInstance, "", "CIM_StorageVolume", nil, nil, false, volumeProperties)
+		if err != nil {
+			return nil, err
+		}
+		for _, volume := range volumes {
+			decode(InstanceWithPath{InstancePath: volume.InstancePath, Instance: volume.Instance})
+		}
+		return details, partialError("ListVolumeDetails", errs)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+
+	for it.Next() {
+		decode(it.Value())
+	}
+	if err = it.Err(); err != nil {
+		return nil, err
+	}
+	return details, partialError("ListVolumeDetails", errs)
+}
+
+////////////////////////////////////////////////////////////////
+//      In-memory index of volumes fetched in a single call   //
+////////////////////////////////////////////////////////////////
+
+type VolumeIndex struct {
+	Volumes       []Volume
+	byDeviceID    map[string]*Volume
+	byElementName map[string][]*Volume
+	byWWN         map[string]*Volume
+}
+
+func NewVolumeIndex(volumes []Volume) *VolumeIndex {
+	idx := &VolumeIndex{
+		Volumes:       volumes,
+		byDeviceID:    make(map[string]*Volume, len(volumes)),
+		byElementName: make(map[string][]*Volume),
+		byWWN:         make(map[string]*Volume, len(volumes)),
+	}
+	for i := range idx.Volumes {
+		vol := &idx.Volumes[i]
+		idx.byDeviceID[vol.DeviceID] = vol
+		if vol.ElementName != "" {
+			idx.byElementName[vol.ElementName] = append(idx.byElementName[vol.ElementName], vol)
+		}
+		if vol.WWN != "" {
+			idx.byWWN[NormalizeWWN(vol.WWN)] = vol
+		}
+	}
+	return idx
+}
+
+func (idx *VolumeIndex) ByDeviceID(deviceID string) (*Volume, bool) {
+	vol, ok := idx.byDeviceID[deviceID]
+	return vol, ok
+}
+
+func (idx *VolumeIndex) ByElementName(elementName string) []*Volume {
+	return idx.byElementName[elementName]
+}
+
+func (idx *VolumeIndex) ByWWN(wwn string) (*Volume, bool) {
+	vol, ok := idx.byWWN[NormalizeWWN(wwn)]
+	return vol, ok
+}
+
+////////////////////////////////////////////////////////////////
+//   NORMALIZE a WWN/NAA: lower case, no "0x"/"naa." prefix,  //
+//              and no ':' or '-' separators                  //
+////////////////////////////////////////////////////////////////
+
+func NormalizeWWN(wwn string) string {
+	wwn = strings.ToLower(strings.TrimSpace(wwn))
+	wwn = strings.TrimPrefix(wwn, "0x")
+	wwn = strings.TrimPrefix(wwn, "naa.")
+	wwn = strings.Replace(wwn, ":", "", -1)
+	return strings.Replace(wwn, "-", "", -1)
+}
+
+///////////////////////////////////////////////////////////////
+//          GET an index of all Storage Volumes              //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) IndexVolumes(systemInstance *gowbem.InstanceName) (*VolumeIndex, error) {
+	return smis.IndexVolumesCtx(context.Background(), systemInstance)
+}
+
+func (smis *SMIS) IndexVolumesCtx(ctx context.Context, systemInstance *gowbem.InstanceName) (*VolumeIndex, error) {
+	volumes, err := smis.ListVolumeDetailsCtx(ctx, systemInstance)
+	var partial *PartialError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+	return NewVolumeIndex(volumes), err
+}
+
+///////////////////////////////////////////////////////////////
+//               EXPAND a Storage Volume                     //
+//      newSizeBytes must be larger than the current size    //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ExpandVolume(systemInstance *gowbem.InstanceName, volume *gowbem.InstanceName, newSizeBytes uint64) error {
+	return smis.ExpandVolumeCtx(context.Background(), systemInstance, volume, newSizeBytes)
+}
+
+func (smis *SMIS) ExpandVolumeCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume *gowbem.InstanceName, newSizeBytes uint64) error {
+	res, err := smis.invokeExpandVolumeCtx(ctx, systemInstance, volume, newSizeBytes)
+	if err != nil {
+		return err
+	}
+	_, err = smis.waitForMethodCtx(ctx, res)
+	return err
+}
+
+func (smis *SMIS) invokeExpandVolumeCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume *gowbem.InstanceName, newSizeBytes uint64) (*methodResult, error) {
+	instance, err := smis.GetInstanceCtx(ctx, volume, false, volumeProperties)
+	if err != nil {
+		return nil, err
+	}
+	current, err := DecodeVolume(volume, instance)
+	if err != nil {
+		return nil, err
+	}
+	if newSizeBytes <= current.CapacityBytes {
+		return nil, errors.New("Volume " + current.DeviceID + ": new size " + strconv.FormatUint(newSizeBytes, 10) +
+			" is not larger than current size " + strconv.FormatUint(current.CapacityBytes, 10))
+	}
+
+	storage, err := smis.GetStorageConfigurationServiceCtx(ctx, systemInstance)
+	if err != nil {
+		return nil, err
+	}
+
+	var params []gowbem.IParamValue
+	params = append(params, gowbem.IParamValue{Name: "TheElement", ValueReference: &gowbem.ValueReference{InstanceName: volume}})
+	params = append(params, gowbem.IParamValue{Name: "Size", Value: &gowbem.Value{strconv.FormatUint(newSizeBytes, 10)}})
+
+	retValue, retValues, err := smis.InvokeMethodCtx(ctx, storage, "CreateOrModifyElementFromStoragePool", params)
+	if err != nil {
+		return nil, err
+	}
+	return &methodResult{"CreateOrModifyElementFromStoragePool", retValue, retValues, "CIM_StorageVolume"}, nil
+}
+
+///////////////////////////////////////////////////////////////
+//               RENAME a Storage Volume                     //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) RenameVolume(volume *gowbem.InstanceName, elementName string) error {
+	return smis.RenameVolumeCtx(context.Background(), volume, elementName)
+}
+
+func (smis *SMIS) RenameVolumeCtx(ctx context.Context, volume *gowbem.InstanceName, elementName string) error {
+	if elementName == "" {
+		return errors.New("Volume ElementName is empty")
+	}
+	return smis.ModifyInstanceCtx(ctx, volume, map[string]interface{}{"ElementName": elementName})
+}
+
+///////////////////////////////////////////////////////////////
+//       Changes applied to a volume by ModifyVolume         //
+//                                                           //
+//   ElementName renames the volume.  StorageGroup adds the  //
+//   volume to this group, which on VMAX3 gives it the       //
+//   group's SLO and workload; it leaves the group that set  //
+//   its SLO until now, and stays in any other group.        //
+//   JournalPath, when set, is where the move is journaled.  //
+//   Zero values are left unchanged.                         //
+///////////////////////////////////////////////////////////////
+
+type VolumeModification struct {
+	ElementName  string
+	StorageGroup *gowbem.InstancePath
+	JournalPath  string
+}
+
+///////////////////////////////////////////////////////////////
+//               MODIFY a Storage Volume                     //
+///////////////////////////////////////////////////////////////
+
+func (smis *SMIS) ModifyVolume(systemInstance *gowbem.InstanceName, volume *gowbem.InstancePath, mod *VolumeModification) error {
+	return smis.ModifyVolumeCtx(context.Background(), systemInstance, volume, mod)
+}
+
+func (smis *SMIS) ModifyVolumeCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume *gowbem.InstancePath, mod *VolumeModification) error {
+	if mod.ElementName != "" {
+		if err := smis.RenameVolumeCtx(ctx, volume.InstanceName, mod.ElementName); err != nil {
+			return err
+		}
+	}
+	if mod.StorageGroup != nil {
+		return smis.moveVolumeToGroupCtx(ctx, systemInstance, volume, mod.StorageGroup, mod.JournalPath)
+	}
+	return nil
+}
+
+// sloGroup returns the storage group that sets the volume's SLO, or nil
+// when none of its groups has one.  A VMAX3 volume is in at most one.
+func sloGroup(groups []gowbem.ValueObjectWithPath) (*gowbem.ValueObjectWithPath, error) {
+	var found *gowbem.ValueObjectWithPath
+	for idx := range groups {
+		slo := propertyString(groups[idx].Instance, "EMCSLO")
+		if slo == "" || strings.EqualFold(slo, "NONE") {
+			continue
+		}
+		if found != nil {
+			return nil, errors.New("Volume is in more than one storage group with an SLO")
+		}
+		found = &groups[idx]
+	}
+	return found, nil
+}
+
+// checkUnmaskedGroupCtx fails when a masking view uses the group, directly
+// or through a parent, since taking the volume out would unmap it.
+func (smis *SMIS) checkUnmaskedGroupCtx(ctx context.Context, group *gowbem.InstanceName) error {
+	groups := []gowbem.InstancePath{{InstanceName: group}}
+	parents, err := smis.groupParentsCtx(ctx, group)
+	if err != nil {
+		return err
+	}
+	for _, sg := range append(groups, parents...) {
+		views, err := smis.groupMaskingViewsCtx(ctx, sg.InstanceName)
+		if err != nil {
+			return err
+		}
+		if len(views) > 0 {
+			return fmt.Errorf("Storage group of the volume is in a masking view: %w", ErrInUse)
+		}
+	}
+	return nil
+}
+
+// moveVolumeToGroupCtx takes the volume out of the group that sets its SLO,
+// since a VMAX3 volume can only be in one group with an SLO, and adds it to
+// the target.  Its other groups are left alone, and a group that a masking
+// view uses is never left, so the move does not unmap the volume.  Both
+// steps are journaled and undone if either fails.
+func (smis *SMIS) moveVolumeToGroupCtx(ctx context.Context, systemInstance *gowbem.InstanceName, volume *gowbem.InstancePath, group *gowbem.InstancePath, journalPath string) error {
+	current, err := smis.AssociatorInstancesCtx(ctx, volume.InstanceName, "", StorageGroupClass, nil, nil, false, []string{"ElementName", "InstanceID", "EMCSLO"})
+	if err != nil {
+		return err
+	}
+	for _, sg := range current {
+		if sameInstanceName(sg.InstancePath.InstanceName, group.InstanceName) {
+			return nil
+		}
+	}
+	from, err := sloGroup(current)
+	if err != nil {
+		return err
+	}
+	if from != nil {
+		if err = smis.checkUnmaskedGroupCtx(ctx, from.InstancePath.InstanceName); err != nil {
+			return err
+		}
+	}
+
+	journal, err := smis.NewJournal(journalPath)
+	if err != nil {
+		return err
+	}
+	members := []gowbem.InstancePath{*volume}
+	if from != nil {
+		err = journal.RemoveMembersFromGroupCtx(ctx, systemInstance, from.InstancePath, members)
+	}
+	if err == nil {
+		err = journal.AddMembersToGroupCtx(ctx, systemInstance, group, members)
+	}
+	return journal.finish(err)
+}
+
+func sameInstanceName(a, b *gowbem.InstanceName) bool {
+	if a == nil || b == nil || a.ClassName != b.ClassName || len(a.KeyBinding) != len(b.KeyBinding) {
+		return false
+	}
+	for _, keyA := range a.KeyBinding {
+		keyB, err := GetKeyFromInstanceName(b, keyA.Name)
+		if err != nil || keyB != keyA.KeyValue.KeyValue {
+			return false
+		}
+	}
+	return true
+}
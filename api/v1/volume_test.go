@@ -0,0 +1,270 @@
+package apiv1
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/kfrodgers/GoWBEM/src/gowbem"
+)
+
+func TestListVolumeDetails(t *testing.T) {
+	vols, err := smis.ListVolumeDetails(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if len(vols) == 0 {
+		t.Log("empty list")
+		t.Fail()
+		return
+	}
+
+	for _, vol := range vols {
+		fmt.Printf("%s %s %d %s\n", vol.DeviceID, vol.ElementName, vol.CapacityBytes, vol.WWN)
+	}
+}
+
+func TestGetVolumeDetails(t *testing.T) {
+	vols, err := smis.GetVolumes(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if len(vols) == 0 {
+		t.Log("empty list")
+		t.Fail()
+		return
+	}
+	volumeId, err := GetKeyFromInstanceName(vols[0].InstancePath.InstanceName, "DeviceID")
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	vol, err := smis.GetVolumeDetails(testingInstance, volumeId.(string))
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if vol.DeviceID != volumeId.(string) {
+		t.Log("DeviceID mismatch: " + vol.DeviceID)
+		t.Fail()
+	}
+	fmt.Printf("%+v\n", *vol)
+}
+
+func TestIndexVolumes(t *testing.T) {
+	idx, err := smis.IndexVolumes(testingInstance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+	if len(idx.Volumes) == 0 {
+		t.Log("empty list")
+		t.Fail()
+		return
+	}
+
+	vol := idx.Volumes[len(idx.Volumes)/2]
+	if found, ok := idx.ByDeviceID(vol.DeviceID); !ok || found.DeviceID != vol.DeviceID {
+		t.Log(vol.DeviceID + ": not found by DeviceID")
+		t.Fail()
+	}
+	if vol.WWN != "" {
+		if found, ok := idx.ByWWN("0x" + strings.ToUpper(vol.WWN)); !ok || found.DeviceID != vol.DeviceID {
+			t.Log(vol.WWN + ": not found by WWN")
+			t.Fail()
+		}
+	}
+	if vol.ElementName != "" && len(idx.ByElementName(vol.ElementName)) == 0 {
+		t.Log(vol.ElementName + ": not found by ElementName")
+		t.Fail()
+	}
+}
+
+func TestExpandVolume(t *testing.T) {
+	pools, _ := smis.GetStoragePools(testingInstance)
+	volumes, err := smis.PostVolumes(&PostVolumesReq{
+		ElementName:        "govmax_test_expand",
+		ElementType:        "2",
+		EMCNumberOfDevices: "1",
+		Size:               "123",
+		InPool:             pools[0].InstancePath.InstanceName,
+	}, testingInstance)
+	if err != nil || len(volumes) == 0 {
+		t.Log("failed to create volume", err)
+		t.Fail()
+		return
+	}
+	volPath := *volumes[0].InstancePath
+	defer smis.PostDeleteVol(testingInstance, []gowbem.InstancePath{volPath})
+
+	instance, _ := smis.GetInstance(volPath.InstanceName, false, nil)
+	before, err := DecodeVolume(volPath.InstanceName, instance)
+	if err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	if err = smis.ExpandVolume(testingInstance, volPath.InstanceName, before.CapacityBytes); err == nil {
+		t.Log("expanding to the same size should fail")
+		t.Fail()
+	}
+
+	if err = smis.ExpandVolume(testingInstance, volPath.InstanceName, before.CapacityBytes*2); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	instance, _ = smis.GetInstance(volPath.InstanceName, false, nil)
+	after, _ := DecodeVolume(volPath.InstanceName, instance)
+	if after == nil || after.CapacityBytes < before.CapacityBytes*2 {
+		t.Log("volume was not expanded")
+		t.Fail()
+	}
+}
+
+func TestRenameVolume(t *testing.T) {
+	pools, _ := smis.GetStoragePools(testingInstance)
+	volumes, err := smis.PostVolumes(&PostVolumesReq{
+		ElementName:        "govmax_test_rename",
+		ElementType:        "2",
+		EMCNumberOfDevices: "1",
+		Size:               "123",
+		InPool:             pools[0].InstancePath.InstanceName,
+	}, testingInstance)
+	if err != nil || len(volumes) == 0 {
+		t.Log("failed to create volume", err)
+		t.Fail()
+		return
+	}
+	volPath := *volumes[0].InstancePath
+	defer smis.PostDeleteVol(testingInstance, []gowbem.InstancePath{volPath})
+
+	if err = smis.RenameVolume(volPath.InstanceName, "govmax_test_renamed"); err != nil {
+		t.Log(err.Error())
+		t.Fail()
+		return
+	}
+
+	instance, _ := smis.GetInstance(volPath.InstanceName, false, nil)
+	if name := propertyString(instance, "ElementName"); name != "govmax_test_renamed" {
+		t.Log("ElementName not changed: " + name)
+		t.Fail()
+	}
+}
+
+// volumePage is an Open or Pull response holding one volume.
+func volumePage(method, deviceID string, eos bool) string {
+	page := `<IMETHODRESPONSE NAME="` + method + `"><IRETURNVALUE><VALUE.INSTANCEWITHPATH>` +
+		`<INSTANCEPATH><NAMESPACEPATH/><INSTANCENAME CLASSNAME="Symm_StorageVolume"><KEYBINDING NAME="DeviceID"><KEYVALUE>` + deviceID + `</KEYVALUE></KEYBINDING></INSTANCENAME></INSTANCEPATH>` +
+		`<INSTANCE CLASSNAME="Symm_StorageVolume"><PROPERTY NAME="DeviceID" TYPE="string"><VALUE>` + deviceID + `</VALUE></PROPERTY></INSTANCE>` +
+		`</VALUE.INSTANCEWITHPATH></IRETURNVALUE>`
+	if eos {
+		return page + `<PARAMVALUE NAME="EndOfSequence"><VALUE>TRUE</VALUE></PARAMVALUE></IMETHODRESPONSE>`
+	}
+	return page + `<PARAMVALUE NAME="EnumerationContext"><VALUE>ctx1</VALUE></PARAMVALUE>` +
+		`<PARAMVALUE NAME="EndOfSequence"><VALUE>FALSE</VALUE></PARAMVALUE></IMETHODRESPONSE>`
+}
+
+func TestListVolumeDetailsPaged(t *testing.T) {
+	var methods []string
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		method := r.Header.Get("CIMMethod")
+		methods = append(methods, method)
+		switch method {
+		case "OpenAssociatorInstances":
+			w.Write([]byte(cimMessage(volumePage(method, "00ABC", false))))
+		case "PullInstancesWithPath":
+			w.Write([]byte(cimMessage(volumePage(method, "00ABD", true))))
+		default:
+			t.Errorf("unexpected %s", method)
+		}
+	})
+	defer server.Close()
+
+	vols, err := client.ListVolumeDetails(testVolumeName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vols) != 2 || vols[0].DeviceID != "00ABC" || vols[1].DeviceID != "00ABD" {
+		t.Errorf("unexpected volumes %+v", vols)
+	}
+	if strings.Join(methods, ",") != "OpenAssociatorInstances,PullInstancesWithPath" {
+		t.Errorf("unexpected calls %v", methods)
+	}
+}
+
+func testSLOGroup(instanceID, slo string) gowbem.ValueObjectWithPath {
+	instance := &gowbem.Instance{}
+	if slo != "" {
+		instance.Property = []gowbem.Property{{Name: "EMCSLO", Value: &gowbem.Value{slo}}}
+	}
+	return gowbem.ValueObjectWithPath{
+		InstancePath: &gowbem.InstancePath{InstanceName: testGroupName(StorageGroupClass, instanceID)},
+		Instance:     instance,
+	}
+}
+
+func TestSLOGroup(t *testing.T) {
+	groups := []gowbem.ValueObjectWithPath{
+		testSLOGroup("host01_SG", ""),
+		testSLOGroup("gold_SG", "Gold"),
+		testSLOGroup("none_SG", "NONE"),
+	}
+	found, err := sloGroup(groups)
+	if err != nil || found != &groups[1] {
+		t.Errorf("expected gold_SG, got %+v %v", found, err)
+	}
+	if found, err = sloGroup(groups[:1]); err != nil || found != nil {
+		t.Errorf("expected no SLO group, got %+v %v", found, err)
+	}
+	if _, err = sloGroup(append(groups, testSLOGroup("silver_SG", "Silver"))); err == nil {
+		t.Error("expected two SLO groups to be rejected")
+	}
+}
+
+func TestModifyVolumeMaskedGroup(t *testing.T) {
+	var methods []string
+	client, server := fakeCIMOM(t, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		method := r.Header.Get("CIMMethod")
+		methods = append(methods, method)
+		switch {
+		case method == "Associators":
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="Associators"><IRETURNVALUE><VALUE.OBJECTWITHPATH>` +
+				`<INSTANCEPATH><NAMESPACEPATH/><INSTANCENAME CLASSNAME="SE_DeviceMaskingGroup"><KEYBINDING NAME="InstanceID"><KEYVALUE>host01_SG</KEYVALUE></KEYBINDING></INSTANCENAME></INSTANCEPATH>` +
+				`<INSTANCE CLASSNAME="SE_DeviceMaskingGroup"><PROPERTY NAME="EMCSLO" TYPE="string"><VALUE>Gold</VALUE></PROPERTY></INSTANCE>` +
+				`</VALUE.OBJECTWITHPATH></IRETURNVALUE></IMETHODRESPONSE>`)))
+		case strings.Contains(string(body), `<CLASSNAME NAME="`+MaskingViewClass+`"/>`):
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="AssociatorNames"><IRETURNVALUE><OBJECTPATH>` +
+				`<INSTANCEPATH><NAMESPACEPATH/><INSTANCENAME CLASSNAME="Symm_LunMaskingView"><KEYBINDING NAME="DeviceID"><KEYVALUE>host01_MV</KEYVALUE></KEYBINDING></INSTANCENAME></INSTANCEPATH>` +
+				`</OBJECTPATH></IRETURNVALUE></IMETHODRESPONSE>`)))
+		default:
+			w.Write([]byte(cimMessage(`<IMETHODRESPONSE NAME="` + method + `"><IRETURNVALUE></IRETURNVALUE></IMETHODRESPONSE>`)))
+		}
+	})
+	defer server.Close()
+
+	silver := &gowbem.InstancePath{InstanceName: testGroupName(StorageGroupClass, "silver_SG")}
+	err := client.ModifyVolume(testVolumeName, &gowbem.InstancePath{InstanceName: testVolumeName}, &VolumeModification{StorageGroup: silver})
+	if !errors.Is(err, ErrInUse) {
+		t.Errorf("expected ErrInUse for a group in a masking view, got %v", err)
+	}
+	for _, method := range methods {
+		if method != "Associators" && method != "AssociatorNames" {
+			t.Errorf("volume moved although its group is masked: %v", methods)
+		}
+	}
+}
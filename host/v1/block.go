@@ -0,0 +1,301 @@
+package hostv1
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// ErrNotFound is wrapped when a lookup, such as the device of a volume WWN,
+// comes back empty.
+var ErrNotFound = errors.New("not found")
+
+// DefaultDevRoot is where device nodes live on a Linux host.
+const DefaultDevRoot = "/dev"
+
+///////////////////////////////////////////////////////////////
+//         RESCAN every SCSI host for new LUNs               //
+///////////////////////////////////////////////////////////////
+
+func (h *Host) RescanSCSIHosts() error {
+	entries, err := ioutil.ReadDir(h.Path("class", "scsi_host"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		scan := h.Path("class", "scsi_host", entry.Name(), "scan")
+		if err = ioutil.WriteFile(scan, []byte("- - -"), 0200); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////////
+//      The SCSI disks and multipath device of a volume       //
+////////////////////////////////////////////////////////////////
+
+type VolumeDevice struct {
+	WWN       string
+	Paths     []string
+	Multipath string
+}
+
+// NormalizeVolumeWWN turns a volume WWN as the array reports it, or as it
+// appears in a wwid or /dev/disk/by-id name (naa., 0x, scsi-3 or wwn-0x
+// prefixed), into lower case hex digits.
+func NormalizeVolumeWWN(wwn string) string {
+	s := strings.ToLower(strings.TrimSpace(wwn))
+	for _, prefix := range []string{"scsi-3", "wwn-0x", "naa.", "0x"} {
+		s = strings.TrimPrefix(s, prefix)
+	}
+	return s
+}
+
+// ParseVPD83 returns the NAA identifier in a Device Identification VPD
+// page (0x83) as hex digits, falling back to an EUI-64 identifier.
+func ParseVPD83(page []byte) (string, error) {
+	if len(page) < 4 || page[1] != 0x83 {
+		return "", errors.New("Invalid VPD page 0x83")
+	}
+	end := 4 + (int(page[2])<<8 | int(page[3]))
+	if end > len(page) {
+		end = len(page)
+	}
+
+	var eui string
+	for i := 4; i+4 <= end; {
+		codeSet := page[i] & 0x0f
+		association := (page[i+1] >> 4) & 0x03
+		designatorType := page[i+1] & 0x0f
+		length := int(page[i+3])
+		if i+4+length > end {
+			break
+		}
+		id := page[i+4 : i+4+length]
+		i += 4 + length
+
+		// Only binary identifiers of the logical unit itself.
+		if codeSet != 1 || association != 0 {
+			continue
+		}
+		switch designatorType {
+		case 3:
+			return hex.EncodeToString(id), nil
+		case 2:
+			if eui == "" {
+				eui = hex.EncodeToString(id)
+			}
+		}
+	}
+	if eui != "" {
+		return eui, nil
+	}
+	return "", fmt.Errorf("VPD page 0x83 identifier %w", ErrNotFound)
+}
+
+// diskWWN reads the identifier of a disk from its raw VPD page, else from
+// the wwid the kernel decoded from it.
+func (h *Host) diskWWN(disk string) (string, error) {
+	page, err := ioutil.ReadFile(h.Path("block", disk, "device", "vpd_pg83"))
+	if err == nil {
+		if wwn, err := ParseVPD83(page); err == nil {
+			return wwn, nil
+		}
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	wwid, err := ReadAttr(h.Path("block", disk, "device", "wwid"))
+	if err != nil {
+		return "", err
+	}
+	return NormalizeVolumeWWN(wwid), nil
+}
+
+// multipath returns the device-mapper device holding a disk, by name under
+// /dev/mapper when it has one.
+func (h *Host) multipath(disk string) (string, error) {
+	holders, err := ioutil.ReadDir(h.Path("block", disk, "holders"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	for _, holder := range holders {
+		if !strings.HasPrefix(holder.Name(), "dm-") {
+			continue
+		}
+		name, err := ReadAttr(h.Path("block", holder.Name(), "dm", "name"))
+		if err != nil {
+			return "", err
+		}
+		if name != "" {
+			return filepath.Join(h.DevRoot, "mapper", name), nil
+		}
+		return filepath.Join(h.DevRoot, holder.Name()), nil
+	}
+	return "", nil
+}
+
+// byIDDisks returns the disks /dev/disk/by-id links to the WWN, for hosts
+// whose sysfs does not expose VPD pages.
+func (h *Host) byIDDisks(wwn string) ([]string, error) {
+	dir := filepath.Join(h.DevRoot, "disk", "by-id")
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var disks []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, "scsi-3") && !strings.HasPrefix(name, "wwn-0x") {
+			continue
+		}
+		if strings.Contains(name, "-part") || NormalizeVolumeWWN(name) != wwn {
+			continue
+		}
+		target, err := os.Readlink(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		if disk := filepath.Base(target); strings.HasPrefix(disk, "sd") {
+			disks = append(disks, disk)
+		}
+	}
+	return disks, nil
+}
+
+///////////////////////////////////////////////////////////////
+//        FIND the devices of a volume by its WWN            //
+///////////////////////////////////////////////////////////////
+
+func (h *Host) FindVolumeDevice(wwn string) (*VolumeDevice, error) {
+	wwn = NormalizeVolumeWWN(wwn)
+	entries, err := ioutil.ReadDir(h.Path("block"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var disks []string
+	for _, entry := range entries {
+		disk := entry.Name()
+		if !strings.HasPrefix(disk, "sd") {
+			continue
+		}
+		// A disk that cannot be read, such as one being removed, is not
+		// the volume.
+		diskWWN, err := h.diskWWN(disk)
+		if err == nil && diskWWN == wwn {
+			disks = append(disks, disk)
+		}
+	}
+	if len(disks) == 0 {
+		if disks, err = h.byIDDisks(wwn); err != nil {
+			return nil, err
+		}
+	}
+	if len(disks) == 0 {
+		return nil, fmt.Errorf("Volume %s %w", wwn, ErrNotFound)
+	}
+
+	device := &VolumeDevice{WWN: wwn}
+	for _, disk := range disks {
+		device.Paths = append(device.Paths, filepath.Join(h.DevRoot, disk))
+		if device.Multipath == "" {
+			if device.Multipath, err = h.multipath(disk); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return device, nil
+}
+
+///////////////////////////////////////////////////////////////
+//     WAIT for a volume to appear, rescanning once first    //
+//                                                           //
+//   With multipath, the volume is ready once multipathd     //
+//   has built its map on top of the SCSI disks.             //
+///////////////////////////////////////////////////////////////
+
+// MultipathWait says whether WaitForDevice also waits for the device-mapper
+// device of a volume.
+type MultipathWait int
+
+const (
+	// MultipathWaitAuto waits when multipath.conf exists under EtcRoot.
+	MultipathWaitAuto MultipathWait = iota
+	MultipathWaitAlways
+	MultipathWaitNever
+)
+
+// devicePollInterval is how often WaitForDevice looks for the volume.
+var devicePollInterval = time.Second
+
+func (h *Host) waitForMultipath() bool {
+	switch h.WaitMultipath {
+	case MultipathWaitAlways:
+		return true
+	case MultipathWaitNever:
+		return false
+	}
+	_, err := os.Stat(h.etcPath("multipath.conf"))
+	return err == nil
+}
+
+func (h *Host) WaitForDevice(wwn string, timeout time.Duration) (*VolumeDevice, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	return h.WaitForDeviceCtx(ctx, wwn)
+}
+
+func (h *Host) WaitForDeviceCtx(ctx context.Context, wwn string) (*VolumeDevice, error) {
+	if err := h.RescanSCSIHosts(); err != nil {
+		return nil, err
+	}
+	waitMultipath := h.waitForMultipath()
+
+	ticker := time.NewTicker(devicePollInterval)
+	defer ticker.Stop()
+	for {
+		device, err := h.FindVolumeDevice(wwn)
+		if err != nil && !errors.Is(err, ErrNotFound) {
+			return nil, err
+		}
+		if err == nil && (device.Multipath != "" || !waitMultipath) {
+			return device, nil
+		}
+		select {
+		case <-ctx.Done():
+			if device != nil {
+				return nil, fmt.Errorf("Multipath device of volume %s: %w", wwn, ctx.Err())
+			}
+			return nil, fmt.Errorf("Volume %s: %w", wwn, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+///////////////////////////////////////////////////////////////
+//       DELETE a SCSI disk from the host through sysfs      //
+///////////////////////////////////////////////////////////////
+
+// DeleteDisk detaches a disk such as sdb from the kernel.  It must no
+// longer be in use, e.g. by a multipath map.
+func (h *Host) DeleteDisk(disk string) error {
+	return ioutil.WriteFile(h.Path("block", filepath.Base(disk), "device", "delete"), []byte("1"), 0200)
+}
@@ -0,0 +1,194 @@
+package hostv1
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+const testVolumeWWN = "60000970000196701380533030314633"
+
+// vpd83 builds a Device Identification page with a T10 vendor designator
+// followed by an NAA designator.
+func vpd83(t *testing.T, naa string) []byte {
+	id, err := hex.DecodeString(naa)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vendor := []byte("EMC     SYMMETRIX")
+	body := append([]byte{0x02, 0x01, 0x00, byte(len(vendor))}, vendor...)
+	body = append(body, 0x01, 0x03, 0x00, byte(len(id)))
+	body = append(body, id...)
+	return append([]byte{0x00, 0x83, byte(len(body) >> 8), byte(len(body))}, body...)
+}
+
+func writeFile(t *testing.T, path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newTestHost(t *testing.T) *Host {
+	root, err := ioutil.TempDir("", "govmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(filepath.Join(root, "sys"))
+	h.DevRoot = filepath.Join(root, "dev")
+	h.EtcRoot = filepath.Join(root, "etc")
+	return h
+}
+
+func TestParseVPD83(t *testing.T) {
+	wwn, err := ParseVPD83(vpd83(t, testVolumeWWN))
+	if err != nil || wwn != testVolumeWWN {
+		t.Errorf("ParseVPD83 = %q, %v", wwn, err)
+	}
+	if _, err = ParseVPD83([]byte{0x00, 0x80, 0x00, 0x00}); err == nil {
+		t.Error("expected page 0x80 to be rejected")
+	}
+	if _, err = ParseVPD83([]byte{0x00, 0x83, 0x00, 0x00}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFindVolumeDevice(t *testing.T) {
+	h := newTestHost(t)
+	defer os.RemoveAll(filepath.Dir(h.SysfsRoot))
+
+	// sdb carries a VPD page, sdc only the decoded wwid; both are paths
+	// of the multipath device dm-0.
+	writeFile(t, h.Path("block", "sdb", "device", "vpd_pg83"), vpd83(t, testVolumeWWN))
+	writeFile(t, h.Path("block", "sdc", "device", "wwid"), []byte("naa."+testVolumeWWN+"\n"))
+	writeFile(t, h.Path("block", "sdd", "device", "wwid"), []byte("naa.60000970000196701380533030314634\n"))
+	writeFile(t, h.Path("block", "sdc", "holders", "dm-0"), nil)
+	writeFile(t, h.Path("block", "dm-0", "dm", "name"), []byte("mpatha\n"))
+
+	device, err := h.FindVolumeDevice("0x" + testVolumeWWN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{filepath.Join(h.DevRoot, "sdb"), filepath.Join(h.DevRoot, "sdc")}
+	if len(device.Paths) != 2 || device.Paths[0] != expected[0] || device.Paths[1] != expected[1] {
+		t.Errorf("expected paths %v, got %v", expected, device.Paths)
+	}
+	if device.Multipath != filepath.Join(h.DevRoot, "mapper", "mpatha") {
+		t.Errorf("unexpected multipath device %q", device.Multipath)
+	}
+
+	if _, err = h.FindVolumeDevice("60000970000196701380533030314699"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestFindVolumeDeviceUnreadableDisk(t *testing.T) {
+	h := newTestHost(t)
+	defer os.RemoveAll(filepath.Dir(h.SysfsRoot))
+
+	// sda cannot be read: its VPD page is a directory.
+	if err := os.MkdirAll(h.Path("block", "sda", "device", "vpd_pg83"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, h.Path("block", "sdb", "device", "vpd_pg83"), vpd83(t, testVolumeWWN))
+
+	device, err := h.FindVolumeDevice(testVolumeWWN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(device.Paths) != 1 || device.Paths[0] != filepath.Join(h.DevRoot, "sdb") {
+		t.Errorf("unexpected paths %v", device.Paths)
+	}
+}
+
+func TestFindVolumeDeviceByID(t *testing.T) {
+	h := newTestHost(t)
+	defer os.RemoveAll(filepath.Dir(h.SysfsRoot))
+
+	byID := filepath.Join(h.DevRoot, "disk", "by-id")
+	if err := os.MkdirAll(byID, 0755); err != nil {
+		t.Fatal(err)
+	}
+	os.Symlink("../../sde", filepath.Join(byID, "scsi-3"+testVolumeWWN))
+	os.Symlink("../../sde1", filepath.Join(byID, "scsi-3"+testVolumeWWN+"-part1"))
+
+	device, err := h.FindVolumeDevice(testVolumeWWN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(device.Paths) != 1 || device.Paths[0] != filepath.Join(h.DevRoot, "sde") {
+		t.Errorf("unexpected paths %v", device.Paths)
+	}
+}
+
+func TestWaitForDevice(t *testing.T) {
+	h := newTestHost(t)
+	defer os.RemoveAll(filepath.Dir(h.SysfsRoot))
+	writeFile(t, h.Path("class", "scsi_host", "host0", "scan"), nil)
+
+	saved := devicePollInterval
+	defer func() { devicePollInterval = saved }()
+	devicePollInterval = 10 * time.Millisecond
+
+	if _, err := h.WaitForDevice(testVolumeWWN, 50*time.Millisecond); err == nil {
+		t.Error("expected WaitForDevice to time out")
+	}
+	if scan, _ := ioutil.ReadFile(h.Path("class", "scsi_host", "host0", "scan")); string(scan) != "- - -" {
+		t.Errorf("expected host0 to be rescanned, got %q", scan)
+	}
+
+	page := vpd83(t, testVolumeWWN)
+	writeFile(t, h.Path("block", "sdb", "device", "wwid"), nil)
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		ioutil.WriteFile(h.Path("block", "sdb", "device", "vpd_pg83"), page, 0644)
+	}()
+	device, err := h.WaitForDevice(testVolumeWWN, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(device.Paths) != 1 {
+		t.Errorf("unexpected paths %v", device.Paths)
+	}
+}
+
+func TestWaitForMultipathDevice(t *testing.T) {
+	h := newTestHost(t)
+	defer os.RemoveAll(filepath.Dir(h.SysfsRoot))
+	writeFile(t, filepath.Join(h.EtcRoot, "multipath.conf"), []byte("defaults {\n}\n"))
+	writeFile(t, h.Path("block", "sdb", "device", "vpd_pg83"), vpd83(t, testVolumeWWN))
+
+	saved := devicePollInterval
+	defer func() { devicePollInterval = saved }()
+	devicePollInterval = 10 * time.Millisecond
+
+	// multipath.conf is present, so the bare disk is not enough.
+	if _, err := h.WaitForDevice(testVolumeWWN, 50*time.Millisecond); err == nil {
+		t.Error("expected WaitForDevice to wait for the multipath device")
+	}
+
+	go func() {
+		time.Sleep(30 * time.Millisecond)
+		writeFile(t, h.Path("block", "dm-0", "dm", "name"), []byte("mpatha\n"))
+		writeFile(t, h.Path("block", "sdb", "holders", "dm-0"), nil)
+	}()
+	device, err := h.WaitForDevice(testVolumeWWN, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if device.Multipath != filepath.Join(h.DevRoot, "mapper", "mpatha") {
+		t.Errorf("unexpected multipath device %q", device.Multipath)
+	}
+
+	h.WaitMultipath = MultipathWaitNever
+	os.RemoveAll(h.Path("block", "sdb", "holders"))
+	if device, err = h.WaitForDevice(testVolumeWWN, time.Second); err != nil || device.Multipath != "" {
+		t.Errorf("expected the bare disk, got %+v %v", device, err)
+	}
+}
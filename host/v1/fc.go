@@ -0,0 +1,210 @@
+package hostv1
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultSysfsRoot is where sysfs is mounted on a Linux host.
+const DefaultSysfsRoot = "/sys"
+
+////////////////////////////////////////////////////////////////
+//      Host side inventory, read from a sysfs tree           //
+////////////////////////////////////////////////////////////////
+
+type Host struct {
+	SysfsRoot     string
+	DevRoot       string
+	EtcRoot       string
+	WaitMultipath MultipathWait
+}
+
+// New returns a Host reading sysfs under root, or under /sys when root is
+// "".  Tests point root at a fake directory tree.
+func New(root string) *Host {
+	if root == "" {
+		root = DefaultSysfsRoot
+	}
+	return &Host{SysfsRoot: root, DevRoot: DefaultDevRoot, EtcRoot: DefaultEtcRoot}
+}
+
+// Path joins elem onto SysfsRoot.
+func (h *Host) Path(elem ...string) string {
+	return filepath.Join(append([]string{h.SysfsRoot}, elem...)...)
+}
+
+func (h *Host) etcPath(elem ...string) string {
+	return filepath.Join(append([]string{h.EtcRoot}, elem...)...)
+}
+
+// ReadAttr returns a sysfs attribute without its trailing newline.  A
+// missing attribute reads as "".
+func ReadAttr(path string) (string, error) {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+////////////////////////////////////////////////////////////////
+//              A Fibre Channel HBA port                      //
+////////////////////////////////////////////////////////////////
+
+type FCHost struct {
+	HostID     string
+	NodeWWN    string
+	PortWWN    string
+	PortState  string
+	Speed      string
+	FabricName string
+	Driver     string
+}
+
+// NormalizeWWN turns a WWN as sysfs or an array prints it (0x prefix,
+// colons, either case) into 16 lower case hex digits.
+func NormalizeWWN(wwn string) (string, error) {
+	s := strings.ToLower(strings.TrimSpace(wwn))
+	s = strings.TrimPrefix(s, "0x")
+	s = strings.Replace(s, ":", "", -1)
+	if len(s) != 16 {
+		return "", errors.New("Invalid WWN " + wwn)
+	}
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return "", errors.New("Invalid WWN " + wwn)
+		}
+	}
+	return s, nil
+}
+
+// unknownFabric is what fabric_name reads when the port is not logged in
+// to a switch.
+const unknownFabric = "ffffffffffffffff"
+
+////////////////////////////////////////////////////////////////
+//    Error returned with a list some items were left out of  //
+////////////////////////////////////////////////////////////////
+
+// PartialError is returned together with the items an inventory call could
+// read; Errors holds the reason for each item left out.  It matches
+// apiv1.PartialError, which this package cannot import.
+type PartialError struct {
+	Op     string
+	Errors []error
+}
+
+func (e *PartialError) Error() string {
+	msg := e.Op + ": " + strconv.Itoa(len(e.Errors)) + " item(s) skipped"
+	if len(e.Errors) > 0 {
+		msg += ", first: " + e.Errors[0].Error()
+	}
+	return msg
+}
+
+///////////////////////////////////////////////////////////////
+//           GET the Fibre Channel HBA ports                 //
+///////////////////////////////////////////////////////////////
+
+// FCHosts returns every FC host it can read.  A host with a malformed
+// attribute is left out and reported in a *PartialError returned with the
+// others.
+func (h *Host) FCHosts() ([]FCHost, error) {
+	dir := h.Path("class", "fc_host")
+	entries, err := ioutil.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []FCHost
+	var errs []error
+	for _, entry := range entries {
+		host, err := h.fcHost(entry.Name())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", entry.Name(), err))
+			continue
+		}
+		hosts = append(hosts, *host)
+	}
+	if len(errs) > 0 {
+		return hosts, &PartialError{Op: "FCHosts", Errors: errs}
+	}
+	return hosts, nil
+}
+
+func (h *Host) fcHost(hostID string) (*FCHost, error) {
+	dir := h.Path("class", "fc_host", hostID)
+	host := &FCHost{HostID: hostID}
+
+	var err error
+	for _, attr := range []struct {
+		name  string
+		value *string
+	}{
+		{"node_name", &host.NodeWWN},
+		{"port_name", &host.PortWWN},
+		{"port_state", &host.PortState},
+		{"speed", &host.Speed},
+		{"fabric_name", &host.FabricName},
+	} {
+		if *attr.value, err = ReadAttr(filepath.Join(dir, attr.name)); err != nil {
+			return nil, err
+		}
+	}
+
+	if host.PortWWN, err = NormalizeWWN(host.PortWWN); err != nil {
+		return nil, err
+	}
+	if host.NodeWWN != "" {
+		if host.NodeWWN, err = NormalizeWWN(host.NodeWWN); err != nil {
+			return nil, err
+		}
+	}
+	if host.FabricName != "" {
+		fabric, err := NormalizeWWN(host.FabricName)
+		if err != nil || fabric == unknownFabric || fabric == "0000000000000000" {
+			fabric = ""
+		}
+		host.FabricName = fabric
+	}
+
+	if host.Driver, err = h.driver(hostID); err != nil {
+		return nil, err
+	}
+	return host, nil
+}
+
+// driver names the kernel module behind a SCSI host, from proc_name or
+// else from the driver link of the PCI device the host sits on.
+func (h *Host) driver(hostID string) (string, error) {
+	name, err := ReadAttr(h.Path("class", "scsi_host", hostID, "proc_name"))
+	if err != nil || name != "" {
+		return name, err
+	}
+	device, err := filepath.EvalSymlinks(h.Path("class", "scsi_host", hostID, "device"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	link, err := os.Readlink(filepath.Join(filepath.Dir(device), "driver"))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return filepath.Base(link), nil
+}
@@ -0,0 +1,142 @@
+package hostv1
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// fakeSysfs builds the sysfs files of one FC host under a temp root.
+func fakeSysfs(t *testing.T, hostID string, attrs map[string]string) string {
+	root, err := ioutil.TempDir("", "govmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pci := filepath.Join(root, "devices", "pci0000:00", "0000:05:00.0")
+	if err = os.MkdirAll(filepath.Join(pci, hostID), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink("../../../bus/pci/drivers/lpfc", filepath.Join(pci, "driver")); err != nil {
+		t.Fatal(err)
+	}
+
+	fcDir := filepath.Join(root, "class", "fc_host", hostID)
+	scsiDir := filepath.Join(root, "class", "scsi_host", hostID)
+	for _, dir := range []string{fcDir, scsiDir} {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Symlink(filepath.Join(pci, hostID), filepath.Join(scsiDir, "device")); err != nil {
+		t.Fatal(err)
+	}
+	for name, value := range attrs {
+		if err = ioutil.WriteFile(filepath.Join(fcDir, name), []byte(value+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestFCHosts(t *testing.T) {
+	root := fakeSysfs(t, "host3", map[string]string{
+		"node_name":   "0x20000000c94e5d22",
+		"port_name":   "0x10000000C94E5D22",
+		"port_state":  "Online",
+		"speed":       "8 Gbit",
+		"fabric_name": "0x100000051e4a6b01",
+	})
+	defer os.RemoveAll(root)
+
+	hosts, err := New(root).FCHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 {
+		t.Fatalf("expected 1 FC host, got %d", len(hosts))
+	}
+	expected := FCHost{
+		HostID:     "host3",
+		NodeWWN:    "20000000c94e5d22",
+		PortWWN:    "10000000c94e5d22",
+		PortState:  "Online",
+		Speed:      "8 Gbit",
+		FabricName: "100000051e4a6b01",
+		Driver:     "lpfc",
+	}
+	if hosts[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, hosts[0])
+	}
+}
+
+func TestFCHostsNotOnFabric(t *testing.T) {
+	root := fakeSysfs(t, "host0", map[string]string{
+		"port_name":   "0x10000000c94e5d23",
+		"port_state":  "Linkdown",
+		"fabric_name": "0xffffffffffffffff",
+	})
+	defer os.RemoveAll(root)
+	ioutil.WriteFile(filepath.Join(root, "class", "scsi_host", "host0", "proc_name"), []byte("qla2xxx\n"), 0644)
+
+	hosts, err := New(root).FCHosts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(hosts) != 1 || hosts[0].FabricName != "" || hosts[0].Driver != "qla2xxx" || hosts[0].Speed != "" {
+		t.Errorf("unexpected FC hosts %+v", hosts)
+	}
+}
+
+func TestFCHostsMalformedHost(t *testing.T) {
+	root := fakeSysfs(t, "host3", map[string]string{
+		"port_name":  "0x10000000c94e5d22",
+		"port_state": "Online",
+	})
+	defer os.RemoveAll(root)
+	badDir := filepath.Join(root, "class", "fc_host", "host4")
+	if err := os.MkdirAll(badDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	ioutil.WriteFile(filepath.Join(badDir, "port_name"), []byte("0xbad\n"), 0644)
+
+	hosts, err := New(root).FCHosts()
+	var partial *PartialError
+	if !errors.As(err, &partial) || len(partial.Errors) != 1 {
+		t.Fatalf("expected a PartialError for host4, got %v", err)
+	}
+	if len(hosts) != 1 || hosts[0].HostID != "host3" || hosts[0].PortWWN != "10000000c94e5d22" {
+		t.Errorf("expected host3 to be listed, got %+v", hosts)
+	}
+}
+
+func TestFCHostsNoFC(t *testing.T) {
+	root, err := ioutil.TempDir("", "govmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+
+	hosts, err := New(root).FCHosts()
+	if err != nil || len(hosts) != 0 {
+		t.Errorf("expected no FC hosts, got %+v %v", hosts, err)
+	}
+}
+
+func TestNormalizeWWN(t *testing.T) {
+	for in, out := range map[string]string{
+		"0x10000000C94E5D22\n":    "10000000c94e5d22",
+		"10:00:00:00:c9:4e:5d:22": "10000000c94e5d22",
+		"10000000c94e5d22":        "10000000c94e5d22",
+	} {
+		if wwn, err := NormalizeWWN(in); err != nil || wwn != out {
+			t.Errorf("NormalizeWWN(%q) = %q, %v", in, wwn, err)
+		}
+	}
+	for _, in := range []string{"", "0x1234", "10000000c94e5d2z"} {
+		if _, err := NormalizeWWN(in); err == nil {
+			t.Errorf("expected NormalizeWWN(%q) to fail", in)
+		}
+	}
+}
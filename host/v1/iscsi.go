@@ -0,0 +1,41 @@
+package hostv1
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DefaultEtcRoot is where a Linux host keeps its configuration files.
+const DefaultEtcRoot = "/etc"
+
+////////////////////////////////////////////////////////////////
+//             GET the iSCSI initiator name                   //
+////////////////////////////////////////////////////////////////
+
+// InitiatorIQN returns the IQN open-iscsi logs in with, from
+// iscsi/initiatorname.iscsi under EtcRoot.
+func (h *Host) InitiatorIQN() (string, error) {
+	name := h.etcPath("iscsi", "initiatorname.iscsi")
+	file, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if value := strings.TrimPrefix(line, "InitiatorName="); value != line && value != "" {
+			return strings.TrimSpace(value), nil
+		}
+	}
+	if err = scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", fmt.Errorf("InitiatorName in %s %w", name, ErrNotFound)
+}
@@ -0,0 +1,41 @@
+package hostv1
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInitiatorIQN(t *testing.T) {
+	root, err := ioutil.TempDir("", "govmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	if err = os.MkdirAll(filepath.Join(root, "iscsi"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	h := New(root)
+	h.EtcRoot = root
+	name := filepath.Join(root, "iscsi", "initiatorname.iscsi")
+
+	content := "## DO NOT EDIT OR REMOVE THIS FILE!\n#InitiatorName=iqn.1994-05.com.redhat:old\nInitiatorName=iqn.1994-05.com.redhat:8a1b2c3d\n"
+	if err = ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	iqn, err := h.InitiatorIQN()
+	if err != nil || iqn != "iqn.1994-05.com.redhat:8a1b2c3d" {
+		t.Log("unexpected IQN", iqn, err)
+		t.Fail()
+	}
+
+	if err = ioutil.WriteFile(name, []byte("# empty\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = h.InitiatorIQN(); !errors.Is(err, ErrNotFound) {
+		t.Log("expected ErrNotFound, got", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,341 @@
+package multipathv1
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	hostv1 "github.com/emccode/govmax/host/v1"
+	"golang.org/x/net/context"
+)
+
+////////////////////////////////////////////////////////////////
+//    dm-multipath on the host, read from sysfs and driven    //
+//              through the multipath command                 //
+////////////////////////////////////////////////////////////////
+
+type Multipath struct {
+	Host *hostv1.Host
+
+	// Exec runs a multipath-tools command and returns its output, killing
+	// it when ctx is done; tests replace it.
+	Exec func(ctx context.Context, name string, args ...string) ([]byte, error)
+}
+
+// New returns a Multipath for host, or for the local host when host is
+// nil.
+func New(host *hostv1.Host) *Multipath {
+	if host == nil {
+		host = hostv1.New("")
+	}
+	return &Multipath{Host: host, Exec: execCommand}
+}
+
+func execCommand(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).CombinedOutput()
+}
+
+////////////////////////////////////////////////////////////////
+//        A multipath map and the SCSI paths under it         //
+////////////////////////////////////////////////////////////////
+
+type Path struct {
+	Disk         string
+	HCTL         string
+	State        string
+	InitiatorWWN string
+	TargetWWN    string
+}
+
+// Running reports whether the SCSI layer can send I/O down the path.
+func (p *Path) Running() bool {
+	return p.State == "running"
+}
+
+type Map struct {
+	Name   string
+	Device string
+	DM     string
+	WWN    string
+	Paths  []Path
+}
+
+// path decodes a disk under a map.  The HCTL is the last element of the
+// disk's device link, and the host and target parts of it name the FC
+// host and remote port the path runs through.  Paths that are not FC, such
+// as iSCSI ones, have no initiator or target WWN.
+func (m *Multipath) path(disk string) (Path, error) {
+	state, err := hostv1.ReadAttr(m.Host.Path("block", disk, "device", "state"))
+	if err != nil {
+		return Path{}, err
+	}
+	p := Path{Disk: disk, State: state}
+	link, err := os.Readlink(m.Host.Path("block", disk, "device"))
+	if os.IsNotExist(err) {
+		return p, nil
+	}
+	if err != nil {
+		return Path{}, err
+	}
+	p.HCTL = filepath.Base(link)
+	hctl := strings.Split(p.HCTL, ":")
+	if len(hctl) != 4 {
+		return p, nil
+	}
+	initiator, err := hostv1.ReadAttr(m.Host.Path("class", "fc_host", "host"+hctl[0], "port_name"))
+	if err != nil {
+		return Path{}, err
+	}
+	target, err := hostv1.ReadAttr(m.Host.Path("class", "fc_transport", "target"+strings.Join(hctl[:3], ":"), "port_name"))
+	if err != nil {
+		return Path{}, err
+	}
+	p.InitiatorWWN, _ = hostv1.NormalizeWWN(initiator)
+	p.TargetWWN, _ = hostv1.NormalizeWWN(target)
+	return p, nil
+}
+
+///////////////////////////////////////////////////////////////
+//        FIND the multipath map of a volume by its WWN      //
+///////////////////////////////////////////////////////////////
+
+// FindMap looks for the device-mapper device whose multipath UUID carries
+// the volume WWN.
+func (m *Multipath) FindMap(wwn string) (*Map, error) {
+	wwn = hostv1.NormalizeVolumeWWN(wwn)
+	entries, err := ioutil.ReadDir(m.Host.Path("block"))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		dm := entry.Name()
+		if !strings.HasPrefix(dm, "dm-") {
+			continue
+		}
+		uuid, err := hostv1.ReadAttr(m.Host.Path("block", dm, "dm", "uuid"))
+		if err != nil {
+			return nil, err
+		}
+		// scsi_id prefixes the NAA identifier with its designator type 3.
+		uuid = strings.ToLower(uuid)
+		if uuid != "mpath-3"+wwn && uuid != "mpath-"+wwn {
+			continue
+		}
+
+		name, err := hostv1.ReadAttr(m.Host.Path("block", dm, "dm", "name"))
+		if err != nil {
+			return nil, err
+		}
+		mp := &Map{
+			Name:   name,
+			Device: filepath.Join(m.Host.DevRoot, "mapper", name),
+			DM:     dm,
+			WWN:    wwn,
+		}
+		slaves, err := ioutil.ReadDir(m.Host.Path("block", dm, "slaves"))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, slave := range slaves {
+			p, err := m.path(slave.Name())
+			if err != nil {
+				return nil, err
+			}
+			mp.Paths = append(mp.Paths, p)
+		}
+		return mp, nil
+	}
+	return nil, fmt.Errorf("Multipath map for %s %w", wwn, hostv1.ErrNotFound)
+}
+
+////////////////////////////////////////////////////////////////
+//     Paths of a map through one front end port login        //
+////////////////////////////////////////////////////////////////
+
+// PortLogin is a login of a host initiator to a front end port, as
+// apiv1.PostPortLogins reports it.  The package takes its own type so it
+// does not depend on the array API.
+type PortLogin struct {
+	InitiatorWWN string
+	Director     string
+	PortNumber   string
+}
+
+// TargetPort is the WWPN of a front end port, as apiv1.ListFrontEndPorts
+// reports it.
+type TargetPort struct {
+	Director   string
+	PortNumber string
+	WWPN       string
+}
+
+type PortState struct {
+	Director     string
+	PortNumber   string
+	InitiatorWWN string
+	TargetWWN    string
+	Paths        []Path
+}
+
+// Running counts the paths through the port that can carry I/O.
+func (s *PortState) Running() int {
+	n := 0
+	for idx := range s.Paths {
+		if s.Paths[idx].Running() {
+			n++
+		}
+	}
+	return n
+}
+
+// PortStates groups the paths of the map by the logins of the host's
+// initiators.  The front end ports give each director and port its WWPN,
+// so a path is placed under the port it reaches.  A login to a port whose
+// WWPN is not given gets no paths, since the initiator alone does not say
+// which port a path reaches.
+func (mp *Map) PortStates(logins []PortLogin, ports []TargetPort) []PortState {
+	targets := make(map[string]string)
+	for idx := range ports {
+		if wwn, err := hostv1.NormalizeWWN(ports[idx].WWPN); err == nil {
+			targets[ports[idx].Director+":"+ports[idx].PortNumber] = wwn
+		}
+	}
+
+	var states []PortState
+	for _, login := range logins {
+		initiator, err := hostv1.NormalizeWWN(login.InitiatorWWN)
+		if err != nil {
+			continue
+		}
+		state := PortState{
+			Director:     login.Director,
+			PortNumber:   login.PortNumber,
+			InitiatorWWN: initiator,
+			TargetWWN:    targets[login.Director+":"+login.PortNumber],
+		}
+		for _, p := range mp.Paths {
+			if state.TargetWWN != "" && p.InitiatorWWN == initiator && p.TargetWWN == state.TargetWWN {
+				state.Paths = append(state.Paths, p)
+			}
+		}
+		states = append(states, state)
+	}
+	return states
+}
+
+///////////////////////////////////////////////////////////////
+//     REMOVE a volume from the host: flush its map, wait    //
+//     for it to go, then delete the SCSI disks under it     //
+///////////////////////////////////////////////////////////////
+
+// mapPollInterval is how often RemoveVolume looks for the flushed map, and
+// mapRemoveTimeout how long it waits for the kernel to drop it.
+var (
+	mapPollInterval  = 100 * time.Millisecond
+	mapRemoveTimeout = 30 * time.Second
+)
+
+// flushBuffers writes out the buffered I/O of a block device.
+func (m *Multipath) flushBuffers(ctx context.Context, device string) error {
+	if out, err := m.Exec(ctx, "blockdev", "--flushbufs", device); err != nil {
+		return fmt.Errorf("blockdev --flushbufs %s: %s: %w", device, strings.TrimSpace(string(out)), err)
+	}
+	return nil
+}
+
+// waitForMapGone waits until the map's dm device has left sysfs, so no disk
+// is deleted while the map may still hold it.
+func (m *Multipath) waitForMapGone(ctx context.Context, mp *Map) error {
+	ctx, cancel := context.WithTimeout(ctx, mapRemoveTimeout)
+	defer cancel()
+
+	ticker := time.NewTicker(mapPollInterval)
+	defer ticker.Stop()
+	for {
+		_, err := os.Stat(m.Host.Path("block", mp.DM))
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("Multipath map %s still present: %w", mp.Name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+func (m *Multipath) RemoveVolume(wwn string) error {
+	return m.RemoveVolumeCtx(context.Background(), wwn)
+}
+
+func (m *Multipath) RemoveVolumeCtx(ctx context.Context, wwn string) error {
+	mp, err := m.FindMap(wwn)
+	if errors.Is(err, hostv1.ErrNotFound) {
+		// No map, but single path disks may still be there.
+		device, err := m.Host.FindVolumeDevice(wwn)
+		if errors.Is(err, hostv1.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		for _, path := range device.Paths {
+			if err = m.flushBuffers(ctx, path); err != nil {
+				return err
+			}
+		}
+		for _, path := range device.Paths {
+			if err = m.Host.DeleteDisk(path); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err = m.flushBuffers(ctx, mp.Device); err != nil {
+		return err
+	}
+	if out, err := m.Exec(ctx, "multipath", "-f", mp.Name); err != nil {
+		return fmt.Errorf("multipath -f %s: %s: %w", mp.Name, strings.TrimSpace(string(out)), err)
+	}
+	if err = m.waitForMapGone(ctx, mp); err != nil {
+		return err
+	}
+	for _, p := range mp.Paths {
+		if err = m.Host.DeleteDisk(p.Disk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////
+//     UNEXPORT a volume, clearing the host side first       //
+///////////////////////////////////////////////////////////////
+
+// UnexportVolume removes the volume's devices from this host and only then
+// calls unexport to take it out of the host's masking view, so no path is
+// left pointing at a LUN the array no longer presents.  unexport is
+// typically a closure over apiv1.UnexportVolumeFromHostCtx.
+func (m *Multipath) UnexportVolume(wwn string, unexport func(context.Context) error) error {
+	return m.UnexportVolumeCtx(context.Background(), wwn, unexport)
+}
+
+func (m *Multipath) UnexportVolumeCtx(ctx context.Context, wwn string, unexport func(context.Context) error) error {
+	if err := m.RemoveVolumeCtx(ctx, wwn); err != nil {
+		return err
+	}
+	return unexport(ctx)
+}
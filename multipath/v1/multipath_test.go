@@ -0,0 +1,253 @@
+package multipathv1
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	hostv1 "github.com/emccode/govmax/host/v1"
+	"golang.org/x/net/context"
+)
+
+const testVolumeWWN = "60000970000196701380533030314633"
+
+func writeFile(t *testing.T, path, data string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, path string) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// fakeMultipath builds mpatha on dm-0 over sdb, running through host3 to
+// target 5000097358150c04, and sdc, offline through host4 to
+// 5000097358150c05.  Its multipath -f drops dm-0 from sysfs as the kernel
+// does.
+func fakeMultipath(t *testing.T) (*Multipath, *[]string) {
+	root, err := ioutil.TempDir("", "govmax")
+	if err != nil {
+		t.Fatal(err)
+	}
+	host := hostv1.New(filepath.Join(root, "sys"))
+	host.DevRoot = filepath.Join(root, "dev")
+	sys := host.SysfsRoot
+
+	writeFile(t, filepath.Join(sys, "block", "dm-0", "dm", "name"), "mpatha\n")
+	writeFile(t, filepath.Join(sys, "block", "dm-0", "dm", "uuid"), "mpath-3"+testVolumeWWN+"\n")
+	for _, p := range []struct{ disk, hctl, state, initiator, target string }{
+		{"sdb", "3:0:0:1", "running", "0x10000000c94e5d22", "0x5000097358150c04"},
+		{"sdc", "4:0:0:1", "offline", "0x10000000c94e5d23", "0x5000097358150c05"},
+	} {
+		device := filepath.Join(sys, "devices", "pci0000:00", p.disk, p.hctl)
+		writeFile(t, filepath.Join(device, "state"), p.state+"\n")
+		symlink(t, device, filepath.Join(sys, "block", p.disk, "device"))
+		writeFile(t, filepath.Join(sys, "block", "dm-0", "slaves", p.disk), "")
+		writeFile(t, filepath.Join(sys, "class", "fc_host", "host"+p.hctl[:1], "port_name"), p.initiator+"\n")
+		writeFile(t, filepath.Join(sys, "class", "fc_transport", "target"+p.hctl[:5], "port_name"), p.target+"\n")
+	}
+
+	var commands []string
+	m := New(host)
+	m.Exec = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		commands = append(commands, name+" "+strings.Join(args, " "))
+		if name == "multipath" && args[0] == "-f" {
+			return nil, os.RemoveAll(filepath.Join(sys, "block", "dm-0"))
+		}
+		return nil, nil
+	}
+	return m, &commands
+}
+
+func TestFindMap(t *testing.T) {
+	m, _ := fakeMultipath(t)
+	defer os.RemoveAll(filepath.Dir(m.Host.SysfsRoot))
+
+	mp, err := m.FindMap("naa." + testVolumeWWN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mp.Name != "mpatha" || mp.DM != "dm-0" || mp.Device != filepath.Join(m.Host.DevRoot, "mapper", "mpatha") {
+		t.Errorf("unexpected map %+v", mp)
+	}
+	if len(mp.Paths) != 2 {
+		t.Fatalf("expected 2 paths, got %+v", mp.Paths)
+	}
+	expected := Path{Disk: "sdb", HCTL: "3:0:0:1", State: "running", InitiatorWWN: "10000000c94e5d22", TargetWWN: "5000097358150c04"}
+	if mp.Paths[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, mp.Paths[0])
+	}
+	if mp.Paths[1].Running() {
+		t.Errorf("expected sdc to be offline, got %+v", mp.Paths[1])
+	}
+
+	if _, err = m.FindMap("60000970000196701380533030314699"); !errors.Is(err, hostv1.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestPortStates(t *testing.T) {
+	m, _ := fakeMultipath(t)
+	defer os.RemoveAll(filepath.Dir(m.Host.SysfsRoot))
+
+	mp, err := m.FindMap(testVolumeWWN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logins := []PortLogin{
+		{InitiatorWWN: "10000000C94E5D22", Director: "FA-1D", PortNumber: "4"},
+		{InitiatorWWN: "10000000C94E5D22", Director: "FA-2D", PortNumber: "4"},
+		{InitiatorWWN: "10000000C94E5D23", Director: "FA-1D", PortNumber: "5"},
+	}
+	ports := []TargetPort{
+		{Director: "FA-1D", PortNumber: "4", WWPN: "5000097358150C04"},
+		{Director: "FA-2D", PortNumber: "4", WWPN: "5000097358150C44"},
+		{Director: "FA-1D", PortNumber: "5", WWPN: "5000097358150C05"},
+	}
+
+	states := mp.PortStates(logins, ports)
+	if len(states) != 3 {
+		t.Fatalf("expected 3 port states, got %+v", states)
+	}
+	for idx, running := range []int{1, 0, 0} {
+		if states[idx].Running() != running {
+			t.Errorf("%s:%s expected %d running paths, got %+v", states[idx].Director, states[idx].PortNumber, running, states[idx].Paths)
+		}
+	}
+	if len(states[2].Paths) != 1 || states[2].Paths[0].Disk != "sdc" {
+		t.Errorf("expected sdc under FA-1D:5, got %+v", states[2].Paths)
+	}
+
+	// Without the port WWPNs no path can be placed under a port.
+	for _, state := range mp.PortStates(logins, nil) {
+		if len(state.Paths) != 0 {
+			t.Errorf("%s:%s expected no paths, got %+v", state.Director, state.PortNumber, state.Paths)
+		}
+	}
+}
+
+func TestFindMapUnreadablePath(t *testing.T) {
+	m, _ := fakeMultipath(t)
+	defer os.RemoveAll(filepath.Dir(m.Host.SysfsRoot))
+
+	// The state of sdc reads as an error: it is a directory.
+	state := filepath.Join(m.Host.SysfsRoot, "devices", "pci0000:00", "sdc", "4:0:0:1", "state")
+	if err := os.Remove(state); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(state, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.FindMap(testVolumeWWN); err == nil || errors.Is(err, hostv1.ErrNotFound) {
+		t.Errorf("expected the read error, got %v", err)
+	}
+}
+
+func TestExecCommandContext(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := execCommand(ctx, "sleep", "5"); err == nil {
+		t.Error("expected the command to be killed")
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("command outlived its context by %v", elapsed)
+	}
+}
+
+func TestRemoveVolume(t *testing.T) {
+	m, commands := fakeMultipath(t)
+	defer os.RemoveAll(filepath.Dir(m.Host.SysfsRoot))
+
+	if err := m.RemoveVolume(testVolumeWWN); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"blockdev --flushbufs " + filepath.Join(m.Host.DevRoot, "mapper", "mpatha"), "multipath -f mpatha"}
+	if strings.Join(*commands, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected %v, ran %v", expected, *commands)
+	}
+	for _, disk := range []string{"sdb", "sdc"} {
+		data, err := ioutil.ReadFile(filepath.Join(m.Host.SysfsRoot, "block", disk, "device", "delete"))
+		if err != nil || string(data) != "1" {
+			t.Errorf("expected %s to be deleted: %v", disk, err)
+		}
+	}
+
+	// A volume that is not on the host is already removed.
+	if err := m.RemoveVolume("60000970000196701380533030314699"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRemoveVolumeFlushFails(t *testing.T) {
+	m, _ := fakeMultipath(t)
+	defer os.RemoveAll(filepath.Dir(m.Host.SysfsRoot))
+	m.Exec = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return []byte("mpatha: map in use\n"), errors.New("exit status 1")
+	}
+
+	if err := m.RemoveVolume(testVolumeWWN); err == nil {
+		t.Error("expected a map in use to fail")
+	}
+	if _, err := os.Stat(filepath.Join(m.Host.SysfsRoot, "block", "sdb", "device", "delete")); !os.IsNotExist(err) {
+		t.Error("disks deleted although the map was not flushed")
+	}
+}
+
+func TestRemoveVolumeMapStays(t *testing.T) {
+	m, _ := fakeMultipath(t)
+	defer os.RemoveAll(filepath.Dir(m.Host.SysfsRoot))
+	m.Exec = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, nil
+	}
+	saved := mapRemoveTimeout
+	defer func() { mapRemoveTimeout = saved }()
+	mapRemoveTimeout = 50 * time.Millisecond
+
+	if err := m.RemoveVolume(testVolumeWWN); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the wait for dm-0 to time out, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(m.Host.SysfsRoot, "block", "sdb", "device", "delete")); !os.IsNotExist(err) {
+		t.Error("disks deleted although the map was still there")
+	}
+}
+
+func TestUnexportVolume(t *testing.T) {
+	m, commands := fakeMultipath(t)
+	defer os.RemoveAll(filepath.Dir(m.Host.SysfsRoot))
+
+	var ran []string
+	err := m.UnexportVolume(testVolumeWWN, func(ctx context.Context) error {
+		ran = append(ran, *commands...)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ran) != 2 {
+		t.Errorf("expected the map to be removed before the unexport, ran %v", ran)
+	}
+
+	unexportErr := errors.New("masking view not found")
+	called := false
+	err = m.UnexportVolume(testVolumeWWN, func(ctx context.Context) error {
+		called = true
+		return unexportErr
+	})
+	if !called || err != unexportErr {
+		t.Errorf("expected the unexport error, got %v", err)
+	}
+}